@@ -0,0 +1,199 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+// Package controls turns whatever the player is holding, be it a mouse,
+// keyboard or gamepad, into the input snapshots the game simulation needs
+package controls
+
+import (
+	"image"
+	"math"
+
+	"github.com/sinisterstuf/lunar-defence/sim"
+)
+
+// An Action is something the player can do, which can be bound to keys and
+// buttons
+type Action int
+
+const (
+	// Fire shoots the laser, or starts the game
+	Fire Action = iota
+	// Pause opens and closes the pause menu
+	Pause
+	// Confirm chooses the selected menu item
+	Confirm
+	// Up moves the crosshair up, or the menu selection
+	Up
+	// Down moves the crosshair down, or the menu selection
+	Down
+	// Left moves the crosshair left
+	Left
+	// Right moves the crosshair right
+	Right
+	// Accelerate speeds up the Moon's orbit, when it can be steered
+	Accelerate
+	// Brake slows down the Moon's orbit, and eventually reverses it
+	Brake
+)
+
+// Actions are all the Actions there are, in order
+var Actions = []Action{Fire, Pause, Confirm, Up, Down, Left, Right, Accelerate, Brake}
+
+var actionNames = []string{"Fire", "Pause", "Confirm", "Up", "Down", "Left", "Right", "Accelerate", "Brake"}
+
+func (a Action) String() string {
+	if a < 0 || int(a) >= len(actionNames) {
+		return "Action(?)"
+	}
+	return actionNames[a]
+}
+
+// A Source is where raw input comes from, usually ebiten but it can be faked
+type Source interface {
+	// HasPointer reports whether there's a mouse pointer to follow at all
+	HasPointer() bool
+	// CursorPosition is where the mouse pointer is
+	CursorPosition() (x, y int)
+	// Clicked reports whether the pointer was just clicked
+	Clicked() bool
+	// Pressed reports whether anything bound to a is held down
+	Pressed(a Action) bool
+	// JustPressed reports whether anything bound to a was pressed this tick
+	JustPressed(a Action) bool
+	// Stick is how far the aiming stick is pushed, from -1 to 1 on each axis
+	Stick() (x, y float64)
+	// Wheel is how far the scroll wheel turned this tick, up is positive
+	Wheel() float64
+	// Number is the number key pressed this tick, from 1 to 9, or 0 for none
+	Number() int
+}
+
+// A Touch is a finger on the screen
+type Touch struct {
+	ID       int
+	Position image.Point
+}
+
+// A TouchSource is where touches come from, usually ebiten but it can be faked
+type TouchSource interface {
+	// NewTouches are the touches that started this tick
+	NewTouches() []Touch
+}
+
+var (
+	CrosshairSpeed float64 = 12   // how far the crosshair moves each tick when pushed all the way
+	StickDeadzone  float64 = 0.15 // how far the stick can drift without moving the crosshair
+)
+
+// A Controller keeps track of where the player is aiming, following the mouse
+// when it moves and otherwise moving the crosshair with keys or a stick.
+//
+// Tapping the screen aims and fires in one go, fingers resting on the screen
+// are ignored and tapping with two fingers at once pauses the game.
+type Controller struct {
+	Source   Source
+	Touches  TouchSource     // optional, for touch screens
+	Bounds   image.Rectangle // the crosshair is kept inside these
+	X, Y     float64         // where the crosshair is
+	Pointed  bool            // whether the last Input was clicked with the pointer or a tap
+	Touching bool            // whether the crosshair was last moved by touch
+	mouse    image.Point
+	started  bool
+}
+
+// NewController makes a Controller for a screen of the given size
+func NewController(source Source, width, height int) *Controller {
+	return &Controller{
+		Source: source,
+		Bounds: image.Rect(0, 0, width, height),
+		X:      float64(width / 2),
+		Y:      float64(height / 2),
+	}
+}
+
+// Input is a snapshot of what the player is doing this tick
+func (c *Controller) Input() sim.Input {
+	c.aim()
+	tapped, twoFingers := c.touch()
+
+	c.Pointed = c.Source.Clicked() || tapped
+	return sim.Input{
+		Cursor:  image.Pt(int(math.Round(c.X)), int(math.Round(c.Y))),
+		Clicked: c.Pointed || c.Source.JustPressed(Fire),
+		Pause:   c.Source.JustPressed(Pause) || twoFingers,
+		Up:      c.Source.JustPressed(Up),
+		Down:    c.Source.JustPressed(Down),
+		Confirm: c.Source.JustPressed(Confirm),
+		Turret:  c.Source.Number(),
+		Thrust:  c.thrust(),
+	}
+}
+
+// thrust is which way the player is pushing the Moon, with keys, buttons or
+// the scroll wheel
+func (c *Controller) thrust() int {
+	wheel := c.Source.Wheel()
+	switch {
+	case c.Source.Pressed(Accelerate) || wheel > 0:
+		return 1
+	case c.Source.Pressed(Brake) || wheel < 0:
+		return -1
+	}
+	return 0
+}
+
+// aim moves the crosshair
+func (c *Controller) aim() {
+	if mouse := image.Pt(c.Source.CursorPosition()); c.Source.HasPointer() && (!c.started || mouse != c.mouse) {
+		c.started = true
+		c.mouse = mouse
+		c.X, c.Y = float64(mouse.X), float64(mouse.Y)
+		c.Touching = false
+		return
+	}
+
+	dx, dy := c.Source.Stick()
+	if math.Hypot(dx, dy) < StickDeadzone {
+		dx, dy = 0, 0
+	}
+	if c.Source.Pressed(Left) {
+		dx--
+	}
+	if c.Source.Pressed(Right) {
+		dx++
+	}
+	if c.Source.Pressed(Up) {
+		dy--
+	}
+	if c.Source.Pressed(Down) {
+		dy++
+	}
+	dx, dy = math.Max(-1, math.Min(1, dx)), math.Max(-1, math.Min(1, dy))
+	if dx != 0 || dy != 0 {
+		c.Touching = false
+	}
+
+	c.X = math.Max(float64(c.Bounds.Min.X), math.Min(float64(c.Bounds.Max.X-1), c.X+dx*CrosshairSpeed))
+	c.Y = math.Max(float64(c.Bounds.Min.Y), math.Min(float64(c.Bounds.Max.Y-1), c.Y+dy*CrosshairSpeed))
+}
+
+// touch moves the crosshair to where the screen was tapped, and reports
+// whether it was tapped with one finger or two
+func (c *Controller) touch() (tapped, twoFingers bool) {
+	if c.Touches == nil {
+		return false, false
+	}
+	touches := c.Touches.NewTouches()
+	switch len(touches) {
+	case 0:
+		return false, false
+	case 1:
+		c.X, c.Y = float64(touches[0].Position.X), float64(touches[0].Position.Y)
+		c.Touching = true
+		return true, false
+	default:
+		return false, true
+	}
+}
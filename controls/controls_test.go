@@ -0,0 +1,183 @@
+package controls
+
+import (
+	"image"
+	"testing"
+)
+
+// fakeSource is a Source controlled by the test
+type fakeSource struct {
+	pointer bool
+	cursor  image.Point
+	clicked bool
+	pressed map[Action]bool
+	just    map[Action]bool
+	stickX  float64
+	stickY  float64
+	wheel   float64
+	number  int
+}
+
+func newFakeSource() *fakeSource {
+	return &fakeSource{pointer: true, pressed: map[Action]bool{}, just: map[Action]bool{}}
+}
+
+func (f *fakeSource) HasPointer() bool           { return f.pointer }
+func (f *fakeSource) CursorPosition() (int, int) { return f.cursor.X, f.cursor.Y }
+func (f *fakeSource) Clicked() bool              { return f.clicked }
+func (f *fakeSource) Pressed(a Action) bool      { return f.pressed[a] }
+func (f *fakeSource) JustPressed(a Action) bool  { return f.just[a] }
+func (f *fakeSource) Stick() (float64, float64)  { return f.stickX, f.stickY }
+func (f *fakeSource) Wheel() float64             { return f.wheel }
+func (f *fakeSource) Number() int                { return f.number }
+
+func TestMouseAim(t *testing.T) {
+	src := newFakeSource()
+	src.cursor = image.Pt(100, 200)
+	c := NewController(src, 1280, 960)
+	if in := c.Input(); in.Cursor != src.cursor {
+		t.Errorf("cursor at %v, want %v", in.Cursor, src.cursor)
+	}
+	src.clicked = true
+	if in := c.Input(); !in.Clicked || !c.Pointed {
+		t.Errorf("mouse click didn't fire")
+	}
+}
+
+func TestKeyboardAim(t *testing.T) {
+	src := newFakeSource()
+	src.cursor = image.Pt(100, 200)
+	c := NewController(src, 1280, 960)
+	c.Input()
+
+	src.pressed[Right] = true
+	src.pressed[Up] = true
+	in := c.Input()
+	want := image.Pt(100+int(CrosshairSpeed), 200-int(CrosshairSpeed))
+	if in.Cursor != want {
+		t.Errorf("cursor at %v, want %v", in.Cursor, want)
+	}
+
+	src.pressed = map[Action]bool{}
+	src.just[Fire] = true
+	if in := c.Input(); !in.Clicked || c.Pointed {
+		t.Errorf("fire key didn't fire")
+	}
+
+	src.just = map[Action]bool{}
+	src.number = 2
+	if in := c.Input(); in.Turret != 2 || in.Choice != 0 {
+		t.Errorf("number key 2 picked turret %d and menu item %d", in.Turret, in.Choice)
+	}
+	src.number = 0
+
+	// The mouse takes over again when it moves
+	src.cursor = image.Pt(5, 5)
+	if in := c.Input(); in.Cursor != src.cursor {
+		t.Errorf("cursor at %v, want %v", in.Cursor, src.cursor)
+	}
+}
+
+func TestNoPointer(t *testing.T) {
+	src := newFakeSource()
+	src.pointer = false
+	c := NewController(src, 1280, 960)
+	if in := c.Input(); in.Cursor != image.Pt(640, 480) {
+		t.Errorf("cursor at %v, want it left in the middle", in.Cursor)
+	}
+
+	src.stickX = 1
+	want := image.Pt(640+int(CrosshairSpeed), 480)
+	if in := c.Input(); in.Cursor != want {
+		t.Errorf("cursor at %v, want %v", in.Cursor, want)
+	}
+}
+
+func TestThrust(t *testing.T) {
+	src := newFakeSource()
+	c := NewController(src, 1280, 960)
+	if in := c.Input(); in.Thrust != 0 {
+		t.Errorf("thrust %d with nothing pressed", in.Thrust)
+	}
+
+	src.pressed[Accelerate] = true
+	if in := c.Input(); in.Thrust != 1 {
+		t.Errorf("thrust %d accelerating, want 1", in.Thrust)
+	}
+
+	src.pressed = map[Action]bool{}
+	src.wheel = -1
+	if in := c.Input(); in.Thrust != -1 {
+		t.Errorf("thrust %d scrolling down, want -1", in.Thrust)
+	}
+}
+
+func TestStickAim(t *testing.T) {
+	src := newFakeSource()
+	c := NewController(src, 1280, 960)
+	c.Input()
+
+	src.stickX = StickDeadzone / 2
+	if in := c.Input(); in.Cursor != image.Pt(0, 0) {
+		t.Errorf("stick drifted inside the deadzone to %v", in.Cursor)
+	}
+
+	src.stickX, src.stickY = 0.5, 1
+	in := c.Input()
+	want := image.Pt(int(CrosshairSpeed/2), int(CrosshairSpeed))
+	if in.Cursor != want {
+		t.Errorf("cursor at %v, want %v", in.Cursor, want)
+	}
+}
+
+func TestBounds(t *testing.T) {
+	src := newFakeSource()
+	c := NewController(src, 100, 100)
+	c.Input()
+	src.pressed[Left] = true
+	src.pressed[Down] = true
+	for i := 0; i < 100; i++ {
+		c.Input()
+	}
+	if in := c.Input(); in.Cursor != image.Pt(0, 99) {
+		t.Errorf("cursor left the screen to %v", in.Cursor)
+	}
+}
+
+// fakeTouches is a TouchSource controlled by the test
+type fakeTouches []Touch
+
+func (f *fakeTouches) NewTouches() []Touch {
+	touches := *f
+	*f = nil
+	return touches
+}
+
+func TestTouch(t *testing.T) {
+	src := newFakeSource()
+	touches := &fakeTouches{}
+	c := NewController(src, 1280, 960)
+	c.Touches = touches
+	c.Input()
+
+	*touches = fakeTouches{{ID: 1, Position: image.Pt(300, 400)}}
+	in := c.Input()
+	if in.Cursor != image.Pt(300, 400) || !in.Clicked || !c.Pointed || !c.Touching {
+		t.Errorf("tap gave %+v", in)
+	}
+
+	// A finger resting on the screen doesn't keep firing
+	if in := c.Input(); in.Clicked || in.Cursor != image.Pt(300, 400) {
+		t.Errorf("held touch gave %+v", in)
+	}
+
+	*touches = fakeTouches{{ID: 2, Position: image.Pt(1, 1)}, {ID: 3, Position: image.Pt(2, 2)}}
+	if in := c.Input(); !in.Pause || in.Clicked {
+		t.Errorf("two finger tap gave %+v", in)
+	}
+
+	src.cursor = image.Pt(10, 10)
+	if c.Input(); c.Touching {
+		t.Errorf("still touching after the mouse moved")
+	}
+}
@@ -25,8 +25,8 @@ func debug(screen *ebiten.Image, g *Game) {
 
 	ebitenutil.DrawRect(
 		screen,
-		float64(g.Crosshair.Center.X-20),
-		float64(g.Crosshair.Center.Y-20),
+		g.World.Crosshair.Center.X-20,
+		g.World.Crosshair.Center.Y-20,
 		40,
 		40,
 		color.RGBA{0, 255, 0, 255},
@@ -34,18 +34,18 @@ func debug(screen *ebiten.Image, g *Game) {
 
 	ebitenutil.DrawLine(
 		screen,
-		float64(g.Earth.Center.X),
-		float64(g.Earth.Center.Y),
-		float64(g.Earth.Center.X)+g.Earth.Radius,
-		float64(g.Earth.Center.Y),
+		g.World.Earth.Center.X,
+		g.World.Earth.Center.Y,
+		g.World.Earth.Center.X+g.World.Earth.Radius,
+		g.World.Earth.Center.Y,
 		color.RGBA{255, 0, 0, 255},
 	)
 
 	mx, my := ebiten.CursorPosition()
 	ebitenutil.DrawLine(
 		screen,
-		float64(g.Earth.Center.X),
-		float64(g.Earth.Center.Y),
+		g.World.Earth.Center.X,
+		g.World.Earth.Center.Y,
 		float64(mx),
 		float64(my),
 		color.RGBA{0, 255, 255, 255},
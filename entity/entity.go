@@ -0,0 +1,189 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+// Package entity keeps track of everything in a game, each thing gets an ID
+// that stays the same for as long as it's around, and what it can do is
+// worked out from which interfaces, its components, it implements
+package entity
+
+import "sort"
+
+// An ID is how an entity is known for as long as it's in a Registry, IDs are
+// never reused and zero is never an ID
+type ID uint32
+
+// Layered entities say which layer they're drawn in, lower layers first, ones
+// that don't say are in layer zero
+type Layered interface {
+	Layer() int
+}
+
+// Timed entities are removed from the Registry once they're Done
+type Timed interface {
+	Done() bool
+}
+
+// entry is an entity and what the Registry knows about it
+type entry struct {
+	id      ID
+	layer   int
+	value   any
+	removed bool
+}
+
+// A Registry is all the entities in a game, in the order they were added.
+// Entities can be added and removed while looping over them, new ones join
+// in once the loop is over and removed ones are skipped straight away
+type Registry struct {
+	entries []entry
+	added   []entry // waiting for the loop to finish
+	layers  []int   // indices of entries in the order they're drawn
+	last    ID
+	looping int  // how many loops over the entries are going on
+	changed bool // whether layers needs sorting again
+}
+
+// New makes an empty Registry
+func New() *Registry {
+	return &Registry{}
+}
+
+// Add puts an entity in the Registry and returns its ID
+func (r *Registry) Add(v any) ID {
+	r.last++
+	e := entry{id: r.last, value: v}
+	if l, ok := v.(Layered); ok {
+		e.layer = l.Layer()
+	}
+	r.added = append(r.added, e)
+	r.tidy()
+	return e.id
+}
+
+// Remove takes an entity out of the Registry, it's not an error if it's
+// already gone
+func (r *Registry) Remove(id ID) {
+	if i, ok := r.find(id); ok {
+		r.entries[i].removed = true
+		r.changed = true
+	}
+	for i := range r.added {
+		if r.added[i].id == id {
+			r.added[i].removed = true
+		}
+	}
+	r.tidy()
+}
+
+// Get finds an entity by its ID
+func (r *Registry) Get(id ID) (any, bool) {
+	if i, ok := r.find(id); ok {
+		return r.entries[i].value, true
+	}
+	for _, e := range r.added {
+		if e.id == id && !e.removed {
+			return e.value, true
+		}
+	}
+	return nil, false
+}
+
+// Len is how many entities there are
+func (r *Registry) Len() int {
+	n := 0
+	for _, e := range r.entries {
+		if !e.removed {
+			n++
+		}
+	}
+	for _, e := range r.added {
+		if !e.removed {
+			n++
+		}
+	}
+	return n
+}
+
+// Expire removes every Timed entity that's Done
+func (r *Registry) Expire() {
+	for i, e := range r.entries {
+		if t, ok := e.value.(Timed); ok && !e.removed && t.Done() {
+			r.entries[i].removed = true
+			r.changed = true
+		}
+	}
+	r.tidy()
+}
+
+// find is where an entity is in the entries, which are always in order of ID
+func (r *Registry) find(id ID) (int, bool) {
+	i := sort.Search(len(r.entries), func(i int) bool { return r.entries[i].id >= id })
+	if i < len(r.entries) && r.entries[i].id == id && !r.entries[i].removed {
+		return i, true
+	}
+	return 0, false
+}
+
+// tidy clears out removed entities and lets in added ones, unless they're
+// being looped over
+func (r *Registry) tidy() {
+	if r.looping > 0 || !r.changed && len(r.added) == 0 {
+		return
+	}
+	kept := r.entries[:0]
+	for _, e := range r.entries {
+		if !e.removed {
+			kept = append(kept, e)
+		}
+	}
+	for _, e := range r.added {
+		if !e.removed {
+			kept = append(kept, e)
+		}
+	}
+	clear(r.entries[min(len(kept), len(r.entries)):])
+	clear(r.added)
+	r.entries, r.added = kept, r.added[:0]
+
+	r.layers = r.layers[:0]
+	for i := range r.entries {
+		r.layers = append(r.layers, i)
+	}
+	sort.SliceStable(r.layers, func(i, j int) bool {
+		return r.entries[r.layers[i]].layer < r.entries[r.layers[j]].layer
+	})
+	r.changed = false
+}
+
+// Each calls fn for every entity with the component C, in the order they
+// were added
+func Each[C any](r *Registry, fn func(ID, C)) {
+	r.looping++
+	defer r.done()
+	for i := range r.entries {
+		e := &r.entries[i]
+		if c, ok := e.value.(C); ok && !e.removed {
+			fn(e.id, c)
+		}
+	}
+}
+
+// Layers calls fn for every entity with the component C, from the lowest
+// layer to the highest, in the order they were added within a layer
+func Layers[C any](r *Registry, fn func(ID, C)) {
+	r.looping++
+	defer r.done()
+	for _, i := range r.layers {
+		e := &r.entries[i]
+		if c, ok := e.value.(C); ok && !e.removed {
+			fn(e.id, c)
+		}
+	}
+}
+
+// done finishes a loop over the entities
+func (r *Registry) done() {
+	r.looping--
+	r.tidy()
+}
@@ -0,0 +1,102 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+)
+
+type thing struct {
+	name  string
+	layer int
+	done  bool
+}
+
+func (t *thing) Layer() int { return t.layer }
+func (t *thing) Done() bool { return t.done }
+
+type plain string
+
+// names are the names of the things with component C, in the order Each or
+// Layers goes through them
+func names[C any](r *Registry, each func(*Registry, func(ID, C))) []string {
+	var got []string
+	each(r, func(_ ID, c C) {
+		switch v := any(c).(type) {
+		case *thing:
+			got = append(got, v.name)
+		case plain:
+			got = append(got, string(v))
+		}
+	})
+	return got
+}
+
+func TestEach(t *testing.T) {
+	r := New()
+	a := r.Add(&thing{name: "a", layer: 2})
+	b := r.Add(plain("b"))
+	c := r.Add(&thing{name: "c", layer: 1})
+	if a == 0 || a == b || b == c || r.Len() != 3 {
+		t.Fatalf("IDs %d, %d and %d for %d entities", a, b, c, r.Len())
+	}
+
+	if got, want := names(r, Each[any]), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Each went through %v, want %v", got, want)
+	}
+	if got, want := names(r, Each[Layered]), []string{"a", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Each with a component went through %v, want %v", got, want)
+	}
+	if got, want := names(r, Layers[any]), []string{"b", "c", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Layers went through %v, want %v", got, want)
+	}
+}
+
+func TestChangesWhileLooping(t *testing.T) {
+	r := New()
+	a := r.Add(plain("a"))
+	b := r.Add(plain("b"))
+	r.Add(plain("c"))
+
+	var seen []string
+	var added ID
+	Each(r, func(id ID, p plain) {
+		seen = append(seen, string(p))
+		if id == a {
+			r.Remove(b)
+			added = r.Add(plain("d"))
+			if v, ok := r.Get(added); !ok || v != plain("d") {
+				t.Errorf("Get(%d) = %v, %v while looping", added, v, ok)
+			}
+		}
+	})
+	if want := []string{"a", "c"}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("went through %v while changing, want %v", seen, want)
+	}
+	if got, want := names(r, Each[plain]), []string{"a", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("went through %v after changing, want %v", got, want)
+	}
+	if _, ok := r.Get(b); ok {
+		t.Errorf("removed entity still there")
+	}
+	if v, ok := r.Get(a); !ok || v != plain("a") {
+		t.Errorf("ID changed after removing another entity")
+	}
+}
+
+func TestExpire(t *testing.T) {
+	r := New()
+	short := &thing{name: "short"}
+	long := &thing{name: "long"}
+	r.Add(short)
+	id := r.Add(long)
+	r.Add(plain("forever"))
+
+	short.done = true
+	r.Expire()
+	if got, want := names(r, Each[any]), []string{"long", "forever"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after expiring left %v, want %v", got, want)
+	}
+	if v, _ := r.Get(id); v != long {
+		t.Errorf("Get(%d) = %v after expiring, want long", id, v)
+	}
+}
@@ -4,7 +4,19 @@ go 1.21
 
 require (
 	github.com/hajimehoshi/ebiten/v2 v2.5.9
-	github.com/stretchr/testify v1.8.4 // indirect
 	golang.org/x/image v0.10.0
 	gopkg.in/ini.v1 v1.67.0
 )
+
+require (
+	github.com/ebitengine/purego v0.4.0 // indirect
+	github.com/go-gl/glfw/v3.3/glfw v0.0.0-20221017161538-93cebf72946b // indirect
+	github.com/hajimehoshi/oto/v2 v2.4.1 // indirect
+	github.com/jezek/xgb v1.1.0 // indirect
+	github.com/jfreymuth/oggvorbis v1.0.5 // indirect
+	github.com/jfreymuth/vorbis v1.0.2 // indirect
+	github.com/stretchr/testify v1.8.4 // indirect
+	golang.org/x/sync v0.1.0 // indirect
+	golang.org/x/sys v0.7.0 // indirect
+	golang.org/x/text v0.11.0 // indirect
+)
@@ -0,0 +1,276 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/sinisterstuf/lunar-defence/controls"
+)
+
+// A Binding is all the keys and buttons that do the same thing
+type Binding struct {
+	Keys    []ebiten.Key
+	Mouse   []ebiten.MouseButton
+	Gamepad []ebiten.StandardGamepadButton
+}
+
+// Bindings say which keys and buttons do which actions
+type Bindings map[controls.Action]Binding
+
+// DefaultBindings are used for anything that isn't configured
+func DefaultBindings() Bindings {
+	return Bindings{
+		controls.Fire: {
+			Keys:    []ebiten.Key{ebiten.KeySpace},
+			Gamepad: []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonFrontBottomRight},
+		},
+		controls.Pause: {
+			Keys:    []ebiten.Key{ebiten.KeyEscape, ebiten.KeyP},
+			Gamepad: []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonCenterRight},
+		},
+		controls.Confirm: {
+			Keys:    []ebiten.Key{ebiten.KeyEnter, ebiten.KeySpace},
+			Gamepad: []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonRightBottom},
+		},
+		controls.Up: {
+			Keys:    []ebiten.Key{ebiten.KeyArrowUp},
+			Gamepad: []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonLeftTop},
+		},
+		controls.Down: {
+			Keys:    []ebiten.Key{ebiten.KeyArrowDown},
+			Gamepad: []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonLeftBottom},
+		},
+		controls.Left: {
+			Keys:    []ebiten.Key{ebiten.KeyArrowLeft},
+			Gamepad: []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonLeftLeft},
+		},
+		controls.Right: {
+			Keys:    []ebiten.Key{ebiten.KeyArrowRight},
+			Gamepad: []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonLeftRight},
+		},
+		controls.Accelerate: {
+			Keys:    []ebiten.Key{ebiten.KeyW},
+			Gamepad: []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonFrontTopRight},
+		},
+		controls.Brake: {
+			Keys:    []ebiten.Key{ebiten.KeyS},
+			Gamepad: []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonFrontTopLeft},
+		},
+	}
+}
+
+var mouseButtonNames = map[string]ebiten.MouseButton{
+	"MouseLeft":   ebiten.MouseButtonLeft,
+	"MouseRight":  ebiten.MouseButtonRight,
+	"MouseMiddle": ebiten.MouseButtonMiddle,
+}
+
+var gamepadButtonNames = map[string]ebiten.StandardGamepadButton{
+	"GamepadRightBottom":      ebiten.StandardGamepadButtonRightBottom,
+	"GamepadRightRight":       ebiten.StandardGamepadButtonRightRight,
+	"GamepadRightLeft":        ebiten.StandardGamepadButtonRightLeft,
+	"GamepadRightTop":         ebiten.StandardGamepadButtonRightTop,
+	"GamepadFrontTopLeft":     ebiten.StandardGamepadButtonFrontTopLeft,
+	"GamepadFrontTopRight":    ebiten.StandardGamepadButtonFrontTopRight,
+	"GamepadFrontBottomLeft":  ebiten.StandardGamepadButtonFrontBottomLeft,
+	"GamepadFrontBottomRight": ebiten.StandardGamepadButtonFrontBottomRight,
+	"GamepadCenterLeft":       ebiten.StandardGamepadButtonCenterLeft,
+	"GamepadCenterRight":      ebiten.StandardGamepadButtonCenterRight,
+	"GamepadLeftStick":        ebiten.StandardGamepadButtonLeftStick,
+	"GamepadRightStick":       ebiten.StandardGamepadButtonRightStick,
+	"GamepadLeftTop":          ebiten.StandardGamepadButtonLeftTop,
+	"GamepadLeftBottom":       ebiten.StandardGamepadButtonLeftBottom,
+	"GamepadLeftLeft":         ebiten.StandardGamepadButtonLeftLeft,
+	"GamepadLeftRight":        ebiten.StandardGamepadButtonLeftRight,
+	"GamepadCenterCenter":     ebiten.StandardGamepadButtonCenterCenter,
+}
+
+// ParseBinding reads a comma separated list of key names like "Space" or
+// "ArrowLeft", mouse buttons like "MouseLeft" and standard gamepad buttons
+// like "GamepadRightBottom"
+func ParseBinding(s string) (Binding, error) {
+	var b Binding
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if button, ok := mouseButtonNames[name]; ok {
+			b.Mouse = append(b.Mouse, button)
+			continue
+		}
+		if button, ok := gamepadButtonNames[name]; ok {
+			b.Gamepad = append(b.Gamepad, button)
+			continue
+		}
+		var key ebiten.Key
+		if err := key.UnmarshalText([]byte(name)); err != nil {
+			return b, fmt.Errorf("unknown key or button %q", name)
+		}
+		b.Keys = append(b.Keys, key)
+	}
+	return b, nil
+}
+
+// An EbitenSource reads input from ebiten according to its Bindings, any
+// gamepad can be used, the mouse always fires and aims and so do touches
+type EbitenSource struct {
+	Bindings    Bindings
+	RightAim    bool // aim with the right stick instead of the left one
+	Gamepad     int  // only use this gamepad, counting from one, 0 for any or -1 for none
+	GamepadOnly bool // ignore the mouse and keyboard, for a second player
+	gamepads    []ebiten.GamepadID
+	touchIDs    []ebiten.TouchID
+	touches     []controls.Touch
+}
+
+// HasPointer reports whether the mouse is used
+func (s *EbitenSource) HasPointer() bool {
+	return !s.GamepadOnly
+}
+
+// CursorPosition is where the mouse pointer is
+func (s *EbitenSource) CursorPosition() (int, int) {
+	if s.GamepadOnly {
+		return 0, 0
+	}
+	return ebiten.CursorPosition()
+}
+
+// Clicked reports whether the left mouse button was just clicked
+func (s *EbitenSource) Clicked() bool {
+	return !s.GamepadOnly && inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft)
+}
+
+// Pressed reports whether anything bound to a is held down
+func (s *EbitenSource) Pressed(a controls.Action) bool {
+	b := s.Bindings[a]
+	if s.GamepadOnly {
+		b.Keys, b.Mouse = nil, nil
+	}
+	for _, k := range b.Keys {
+		if ebiten.IsKeyPressed(k) {
+			return true
+		}
+	}
+	for _, m := range b.Mouse {
+		if ebiten.IsMouseButtonPressed(m) {
+			return true
+		}
+	}
+	for _, id := range s.gamepadIDs() {
+		for _, g := range b.Gamepad {
+			if ebiten.IsStandardGamepadButtonPressed(id, g) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// JustPressed reports whether anything bound to a was pressed this tick
+func (s *EbitenSource) JustPressed(a controls.Action) bool {
+	b := s.Bindings[a]
+	if s.GamepadOnly {
+		b.Keys, b.Mouse = nil, nil
+	}
+	for _, k := range b.Keys {
+		if inpututil.IsKeyJustPressed(k) {
+			return true
+		}
+	}
+	for _, m := range b.Mouse {
+		if inpututil.IsMouseButtonJustPressed(m) {
+			return true
+		}
+	}
+	for _, id := range s.gamepadIDs() {
+		for _, g := range b.Gamepad {
+			if inpututil.IsStandardGamepadButtonJustPressed(id, g) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Stick is how far the aiming stick of any gamepad is pushed
+func (s *EbitenSource) Stick() (x, y float64) {
+	horizontal := ebiten.StandardGamepadAxisLeftStickHorizontal
+	vertical := ebiten.StandardGamepadAxisLeftStickVertical
+	if s.RightAim {
+		horizontal = ebiten.StandardGamepadAxisRightStickHorizontal
+		vertical = ebiten.StandardGamepadAxisRightStickVertical
+	}
+	for _, id := range s.gamepadIDs() {
+		gx := ebiten.StandardGamepadAxisValue(id, horizontal)
+		gy := ebiten.StandardGamepadAxisValue(id, vertical)
+		if gx*gx+gy*gy > x*x+y*y {
+			x, y = gx, gy
+		}
+	}
+	return x, y
+}
+
+// Wheel is how far the mouse wheel was scrolled this tick
+func (s *EbitenSource) Wheel() float64 {
+	if s.GamepadOnly {
+		return 0
+	}
+	_, y := ebiten.Wheel()
+	return y
+}
+
+// Number is the number key pressed this tick, from 1 to 9, or 0 for none
+func (s *EbitenSource) Number() int {
+	if s.GamepadOnly {
+		return 0
+	}
+	for k := ebiten.KeyDigit1; k <= ebiten.KeyDigit9; k++ {
+		if inpututil.IsKeyJustPressed(k) {
+			return int(k-ebiten.KeyDigit1) + 1
+		}
+	}
+	return 0
+}
+
+// NewTouches are the touches that started this tick
+func (s *EbitenSource) NewTouches() []controls.Touch {
+	s.touchIDs = inpututil.AppendJustPressedTouchIDs(s.touchIDs[:0])
+	s.touches = s.touches[:0]
+	for _, id := range s.touchIDs {
+		s.touches = append(s.touches, controls.Touch{
+			ID:       int(id),
+			Position: image.Pt(ebiten.TouchPosition(id)),
+		})
+	}
+	return s.touches
+}
+
+// gamepadIDs are the connected gamepads with a standard layout, or just the
+// one the source is limited to
+func (s *EbitenSource) gamepadIDs() []ebiten.GamepadID {
+	s.gamepads = ebiten.AppendGamepadIDs(s.gamepads[:0])
+	ids := s.gamepads[:0]
+	for _, id := range s.gamepads {
+		if ebiten.IsStandardGamepadLayoutAvailable(id) {
+			ids = append(ids, id)
+		}
+	}
+	switch {
+	case s.Gamepad < 0:
+		return nil
+	case s.Gamepad > 0 && s.Gamepad <= len(ids):
+		return ids[s.Gamepad-1 : s.Gamepad]
+	case s.Gamepad > 0:
+		return nil
+	}
+	return ids
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+func TestParseBinding(t *testing.T) {
+	got, err := ParseBinding("Space, a,MouseLeft , GamepadRightBottom,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Binding{
+		Keys:    []ebiten.Key{ebiten.KeySpace, ebiten.KeyA},
+		Mouse:   []ebiten.MouseButton{ebiten.MouseButtonLeft},
+		Gamepad: []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonRightBottom},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseBinding() = %v, want %v", got, want)
+	}
+
+	if _, err := ParseBinding("Space, Banana"); err == nil {
+		t.Errorf("parsed an unknown key")
+	}
+}
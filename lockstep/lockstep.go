@@ -0,0 +1,281 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+// Package lockstep keeps two copies of the game in step over a network
+// connection for versus mode, every tick both ends swap their player's input
+// and simulate the same combined input, which keeps the games the same as
+// long as the simulation is deterministic
+package lockstep
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/sinisterstuf/lunar-defence/sim"
+)
+
+const (
+	magic   = "LDLS"
+	version = 3
+)
+
+// maxGameplay is the most the host's Gameplay can take up, so a broken
+// greeting can't make us allocate gigabytes
+const maxGameplay = 1 << 20
+
+// Delay is how many ticks late input is played, so the other end's input has
+// time to arrive before it's needed
+var Delay int = 3
+
+// ErrDesync is when the two ends of a game don't agree on its state any more
+var ErrDesync = errors.New("games out of sync")
+
+// A Role is which side a player is on
+type Role int
+
+const (
+	// Defender shoots asteroids, like in a normal game
+	Defender Role = iota
+	// Attacker launches asteroids at the Defender's Earth
+	Attacker
+)
+
+func (r Role) String() string {
+	if r == Attacker {
+		return "attacker"
+	}
+	return "defender"
+}
+
+// A Peer is one end of a versus game
+type Peer struct {
+	Role     Role
+	Seed     int64         // what both ends seed their World with
+	Gameplay *sim.Gameplay // the host's settings and waves, which both ends play with
+	Delay    int           // ticks of input delay, the host decides
+	conn     net.Conn
+	r        *bufio.Reader
+	tick     int            // the next tick to be played
+	queue    []sim.Input    // this end's input that was sent but not played yet
+	checks   map[int]uint32 // this end's checksums, until the other end's arrive
+}
+
+// Host waits for the other player to join on l and starts a game with them,
+// the host defends and tells the attacker the seed and gameplay
+func Host(l net.Listener, seed int64, gameplay *sim.Gameplay) (*Peer, error) {
+	settings, err := gameplay.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := l.Accept()
+	if err != nil {
+		return nil, fmt.Errorf("waiting for the other player: %w", err)
+	}
+	p := newPeer(conn, Defender, seed, Delay)
+	p.Gameplay = gameplay
+
+	buf := append([]byte(magic), version)
+	buf = binary.AppendVarint(buf, seed)
+	buf = binary.AppendUvarint(buf, uint64(p.Delay))
+	buf = binary.AppendUvarint(buf, uint64(len(settings)))
+	buf = append(buf, settings...)
+	if _, err := conn.Write(buf); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("greeting the other player: %w", err)
+	}
+	if err := p.readHello(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return p, p.start()
+}
+
+// Join connects to a game hosted at addr, to attack
+func Join(addr string) (*Peer, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("joining %s: %w", addr, err)
+	}
+	p := newPeer(conn, Attacker, 0, 0)
+	if err := p.readHello(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if p.Seed, err = binary.ReadVarint(p.r); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("reading the seed: %w", err)
+	}
+	delay, err := binary.ReadUvarint(p.r)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("reading the input delay: %w", err)
+	}
+	p.Delay = int(delay)
+	if p.Gameplay, err = p.readGameplay(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if _, err := conn.Write(append([]byte(magic), version)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("greeting the host: %w", err)
+	}
+	return p, p.start()
+}
+
+func newPeer(conn net.Conn, role Role, seed int64, delay int) *Peer {
+	return &Peer{
+		Role:   role,
+		Seed:   seed,
+		Delay:  delay,
+		conn:   conn,
+		r:      bufio.NewReader(conn),
+		checks: map[int]uint32{},
+	}
+}
+
+// readHello checks the other end is playing the same game
+func (p *Peer) readHello() error {
+	hello := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(p.r, hello); err != nil {
+		return fmt.Errorf("reading the other player's greeting: %w", err)
+	}
+	if string(hello[:len(magic)]) != magic {
+		return errors.New("the other end isn't playing Lunar Defence")
+	}
+	if v := hello[len(magic)]; v != version {
+		return fmt.Errorf("the other player has version %d of versus mode, not %d", v, version)
+	}
+	return nil
+}
+
+// readGameplay reads the host's Gameplay
+func (p *Peer) readGameplay() (*sim.Gameplay, error) {
+	size, err := binary.ReadUvarint(p.r)
+	if err != nil {
+		return nil, fmt.Errorf("reading the gameplay: %w", err)
+	}
+	if size > maxGameplay {
+		return nil, fmt.Errorf("the host's gameplay is %d bytes long", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(p.r, data); err != nil {
+		return nil, fmt.Errorf("reading the gameplay: %w", err)
+	}
+	gameplay := &sim.Gameplay{}
+	if err := gameplay.UnmarshalBinary(data); err != nil {
+		return nil, fmt.Errorf("reading the gameplay: %w", err)
+	}
+	return gameplay, nil
+}
+
+// start sends empty input for the first few ticks, to make up the delay
+func (p *Peer) start() error {
+	for i := 0; i < p.Delay; i++ {
+		p.queue = append(p.queue, sim.Input{})
+		if err := p.send(i, sim.Input{}, 0); err != nil {
+			p.conn.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+// Close hangs up on the other player
+func (p *Peer) Close() error {
+	return p.conn.Close()
+}
+
+// Step sends this player's input and returns the input to play this tick,
+// combined from what both players did Delay ticks ago, it waits until the
+// other player's input arrives. check is the World's Checksum before this
+// tick, if it differs from the other end's it returns ErrDesync
+func (p *Peer) Step(local sim.Input, check uint32) (sim.Input, error) {
+	tick := p.tick
+	p.checks[tick] = check
+	p.queue = append(p.queue, local)
+	if err := p.send(tick+p.Delay, local, check); err != nil {
+		return sim.Input{}, err
+	}
+
+	remote, err := p.receive(tick)
+	if err != nil {
+		return sim.Input{}, err
+	}
+	mine := p.queue[0]
+	p.queue = p.queue[1:]
+	p.tick++
+
+	if p.Role == Defender {
+		return Combine(mine, remote), nil
+	}
+	return Combine(remote, mine), nil
+}
+
+// Combine makes one tick's input from both players' input, the defender aims
+// and uses the menus while the attacker chooses where to launch from, and
+// either can pause
+func Combine(defender, attacker sim.Input) sim.Input {
+	in := defender
+	in.Player2 = sim.Aim{Cursor: attacker.Cursor, Clicked: attacker.Clicked}
+	in.Pause = in.Pause || attacker.Pause
+	return in
+}
+
+// send writes the input for a tick, along with the checksum from Delay ticks
+// before it
+func (p *Peer) send(tick int, in sim.Input, check uint32) error {
+	data, err := in.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	buf := binary.AppendUvarint(nil, uint64(tick))
+	buf = binary.BigEndian.AppendUint32(buf, check)
+	buf = binary.AppendUvarint(buf, uint64(len(data)))
+	buf = append(buf, data...)
+	if _, err := p.conn.Write(buf); err != nil {
+		return fmt.Errorf("sending tick %d: %w", tick, err)
+	}
+	return nil
+}
+
+// receive reads the other player's input for a tick, and checks that their
+// game was the same as ours Delay ticks before
+func (p *Peer) receive(tick int) (sim.Input, error) {
+	var in sim.Input
+	got, err := binary.ReadUvarint(p.r)
+	if err != nil {
+		return in, fmt.Errorf("receiving tick %d: %w", tick, err)
+	}
+	if int(got) != tick {
+		return in, fmt.Errorf("received tick %d, expected %d", got, tick)
+	}
+	var check [4]byte
+	if _, err := io.ReadFull(p.r, check[:]); err != nil {
+		return in, fmt.Errorf("receiving tick %d: %w", tick, err)
+	}
+	size, err := binary.ReadUvarint(p.r)
+	if err != nil {
+		return in, fmt.Errorf("receiving tick %d: %w", tick, err)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(p.r, data); err != nil {
+		return in, fmt.Errorf("receiving tick %d: %w", tick, err)
+	}
+	if err := in.UnmarshalBinary(data); err != nil {
+		return in, fmt.Errorf("receiving tick %d: %w", tick, err)
+	}
+
+	// The first few ticks were sent before there was a game to check
+	if checked := tick - p.Delay; checked >= 0 {
+		if binary.BigEndian.Uint32(check[:]) != p.checks[checked] {
+			return in, fmt.Errorf("%w on tick %d", ErrDesync, checked)
+		}
+		delete(p.checks, checked)
+	}
+	return in, nil
+}
@@ -0,0 +1,150 @@
+package lockstep
+
+import (
+	"errors"
+	"image"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/sinisterstuf/lunar-defence/sim"
+)
+
+// gameplay is what the host plays with
+var gameplay = &sim.Gameplay{
+	MoonKnockback: true,
+	Waves:         sim.Waves{{Count: 8, Spread: 5, Speed: 1.5}, {Count: 12, Spread: -1, Speed: 1}},
+}
+
+// connect hosts a game on localhost and joins it
+func connect(t *testing.T, seed int64) (host, guest *Peer) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	hosted := make(chan error, 1)
+	go func() {
+		var err error
+		host, err = Host(l, seed, gameplay)
+		hosted <- err
+	}()
+	guest, err = Join(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-hosted; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		host.Close()
+		guest.Close()
+	})
+	return host, guest
+}
+
+// play runs a versus game on one end, with input from script, and returns
+// the World once it's played ticks ticks
+func play(p *Peer, ticks int, script func(tick int) sim.Input, cheat func(*sim.World)) (*sim.World, error) {
+	w := sim.NewWorld(1280, 960, sim.DefaultSizes, p.Seed)
+	w.Waves = p.Gameplay.Waves
+	w.Versus = true
+	for i := 0; i < ticks; i++ {
+		in, err := p.Step(script(i), w.Checksum())
+		if err != nil {
+			return w, err
+		}
+		w.Update(in)
+		if cheat != nil {
+			cheat(w)
+		}
+	}
+	return w, nil
+}
+
+func defend(tick int) sim.Input {
+	return sim.Input{
+		Cursor:  image.Pt(300+tick%700, 200+(tick*3)%500),
+		Clicked: tick == 0 || tick%40 == 0,
+	}
+}
+
+func attack(tick int) sim.Input {
+	return sim.Input{
+		Cursor:  image.Pt(tick%1280, 0),
+		Clicked: tick%20 == 0,
+	}
+}
+
+func TestVersus(t *testing.T) {
+	host, guest := connect(t, 42)
+	if host.Role != Defender || guest.Role != Attacker {
+		t.Fatalf("host is the %v and guest the %v", host.Role, guest.Role)
+	}
+	if guest.Seed != 42 || guest.Delay != host.Delay {
+		t.Fatalf("guest got seed %d and delay %d", guest.Seed, guest.Delay)
+	}
+	if !reflect.DeepEqual(guest.Gameplay, gameplay) {
+		t.Fatalf("guest got gameplay %+v, want %+v", guest.Gameplay, gameplay)
+	}
+
+	ticks := sim.TPS * 20
+	done := make(chan *sim.World, 1)
+	go func() {
+		w, err := play(guest, ticks, attack, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- w
+	}()
+	defender, err := play(host, ticks, defend, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attacker := <-done
+
+	if defender.Checksum() != attacker.Checksum() {
+		t.Errorf("games differ after %d ticks", ticks)
+	}
+	if defender.Count != attacker.Count || defender.Wave != attacker.Wave || defender.Earth.Impacted != attacker.Earth.Impacted {
+		t.Errorf("defender has %d asteroids on wave %d, attacker %d on wave %d",
+			defender.Count, defender.Wave, attacker.Count, attacker.Wave)
+	}
+	if defender.Wave < 1 {
+		t.Errorf("game never started")
+	}
+}
+
+func TestDesync(t *testing.T) {
+	host, guest := connect(t, 7)
+	ticks := sim.TPS * 2
+	go play(guest, ticks, attack, nil)
+
+	// The host's game goes its own way after a second
+	cheat := func(w *sim.World) {
+		if w.Scheduler.Now == sim.TPS {
+			w.Score += 1000
+		}
+	}
+	_, err := play(host, ticks, defend, cheat)
+	if !errors.Is(err, ErrDesync) {
+		t.Errorf("got %v, want a desync", err)
+	}
+}
+
+func TestCombine(t *testing.T) {
+	defender := sim.Input{Cursor: image.Pt(1, 2), Clicked: true, Choice: 3}
+	attacker := sim.Input{Cursor: image.Pt(4, 5), Clicked: true, Pause: true, Choice: 1}
+	want := sim.Input{
+		Cursor:  image.Pt(1, 2),
+		Clicked: true,
+		Choice:  3,
+		Pause:   true,
+		Player2: sim.Aim{Cursor: image.Pt(4, 5), Clicked: true},
+	}
+	if got := Combine(defender, attacker); got != want {
+		t.Errorf("Combine() = %+v, want %+v", got, want)
+	}
+}
@@ -2,18 +2,28 @@
 // Use of this source code is subject to an MIT-style
 // licence which can be found in the LICENSE file.
 
+// Lunar Defence is a game about shooting asteroids from the Moon before they
+// hit the Earth. This package draws the sim package's World and plays its
+// sounds with Ebiten, keeping what's on the screen in an entity.Registry that
+// is drawn back to front by layer. The World moves on at a fixed sim.TPS
+// ticks a second and the screen is drawn a tick behind it, with everything
+// that moves drawn part of the way between where it was and where it is, so
+// the motion stays smooth whatever the screen's refresh rate.
 package main
 
 import (
 	"bytes"
 	"embed"
 	"errors"
+	"flag"
 	"fmt"
 	"image"
 	"image/color"
+	"io/fs"
 	"log"
-	"math"
-	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
 	"strconv"
 	"time"
 
@@ -23,22 +33,24 @@ import (
 	"github.com/hajimehoshi/ebiten/v2/examples/resources/fonts"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/sinisterstuf/lunar-defence/controls"
+	"github.com/sinisterstuf/lunar-defence/entity"
+	"github.com/sinisterstuf/lunar-defence/lockstep"
+	"github.com/sinisterstuf/lunar-defence/sim"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/opentype"
 	"gopkg.in/ini.v1"
 )
 
-var (
-	HowManyStart       int     = 5
-	EdgeOfScreenOffset float64 = 3
-	DistanceVariance   float64 = 7
-	TimeBetweenWaves   int     = 2
-	WaveMultiplier     int     = 2
-	RotationSpeed      float64 = 0.02
-	MoonOrbitRatio     float64 = 2
-	MoonOrbitDistance  float64 = 5
-	AsteroidSpinRatio  float64 = 3
-)
+// Seed is what asteroid waves are generated from, zero picks a random one
+var Seed int64
+
+// Controls are the player's key and button bindings
+var Controls = &EbitenSource{Bindings: DefaultBindings()}
+
+// Player2Controls are the second player's, who only has a gamepad, in co-op
+var Player2Controls = &EbitenSource{Bindings: Controls.Bindings, Gamepad: 1, GamepadOnly: true}
 
 //go:embed assets/*.png assets/*.ogg
 var assets embed.FS
@@ -48,70 +60,126 @@ func main() {
 	ebiten.SetWindowTitle("Lunar Defence")
 	ebiten.SetWindowIcon([]image.Image{loadImage("assets/icon.png")})
 	ebiten.SetCursorMode(ebiten.CursorModeHidden)
+	ebiten.SetTPS(sim.TPS)
+
+	seed := flag.Int64("seed", 0, "generate waves from this seed, the same seed always gives the same waves (default random)")
+	replayFile := flag.String("replay", "", "play back a game from a replay `file`")
+	wavesFile := flag.String("waves", "lunar-defence-waves.ini", "load waves from this `file`, if it exists")
+	recordFile := flag.String("record", "lunar-defence.replay", "record the game to a replay `file` when quitting, empty to not record")
+	hostAddr := flag.String("host", "", "host a versus game on this `address`, e.g. :7070, and defend")
+	joinAddr := flag.String("join", "", "join a versus game hosted at this `address`, e.g. localhost:7070, and attack")
+	flag.Parse()
 
 	applyConfigs()
+	if *seed != 0 {
+		Seed = *seed
+	}
+	if Seed == 0 {
+		Seed = time.Now().UnixNano()
+	}
+
+	// Replays and versus games keep the waves and the settings that change how
+	// the game plays the most, the rest of lunar-defence.ini has to stay the
+	// same to play them back or play against someone
+	var replay *sim.Replay
+	var gameplay *sim.Gameplay
+	if *replayFile != "" {
+		replay = loadReplay(*replayFile)
+		Seed = replay.Seed
+		gameplay = replay.Gameplay
+		if sameFile(*replayFile, *recordFile) {
+			log.Printf("not recording over %s while it plays back\n", *recordFile)
+			*recordFile = ""
+		}
+	}
+	if gameplay == nil && *joinAddr == "" { // attackers play the host's
+		gameplay = &sim.Gameplay{
+			CoOp:          sim.CoOp,
+			MoonSteering:  sim.MoonSteering,
+			MoonKnockback: sim.MoonKnockback,
+			Waves:         loadWaves(*wavesFile),
+		}
+	}
+	peer := connect(*hostAddr, *joinAddr, gameplay)
+	if peer != nil {
+		Seed = peer.Seed
+		replay = nil
+		gameplay = peer.Gameplay
+		gameplay.CoOp = false // versus has the second player attacking instead
+	}
+	sim.CoOp = gameplay.CoOp
+	sim.MoonSteering = gameplay.MoonSteering
+	sim.MoonKnockback = gameplay.MoonKnockback
+	log.Printf("seed: %d\n", Seed)
 
 	gameWidth, gameHeight := 1280, 960
-	rand.Seed(time.Now().UnixNano())
-	howMany := HowManyStart // starting number of asteroids
 	fontFace := loadFont()
 
+	world := sim.NewWorld(gameWidth, gameHeight, sim.DefaultSizes, Seed)
+	world.HighScores = loadHighScores()
+	world.Waves = gameplay.Waves
+	world.Versus = peer != nil
+	world.Enter(sim.Loading)
+
 	game := &Game{
-		Width:      gameWidth,
-		Height:     gameHeight,
-		FontFace:   fontFace,
-		Loading:    true,
-		GameOver:   false,
-		Breathless: false,
-		Rotation:   0,
-		Count:      0,
-		Wave:       0,
-		HowMany:    howMany,
-		Moon:       nil,
-		Earth:      nil,
-		Asteroids:  nil,
-		Crosshair:  nil,
-		GOText:     nil,
-		Entities:   nil,
-		Sounds:     nil,
+		Width:     gameWidth,
+		Height:    gameHeight,
+		FontFace:  fontFace,
+		Loaded:    make(chan struct{}),
+		World:     world,
+		Controls:  controls.NewController(Controls, gameWidth, gameHeight),
+		Moon:      nil,
+		Earth:     nil,
+		Crosshair: nil,
+		GOText:    nil,
+		Entities:  nil,
+		Sounds:    nil,
+		Replay:    replay,
+		Recording: &sim.Replay{Seed: Seed, Gameplay: gameplay},
+		Peer:      peer,
+	}
+
+	game.Controls.Touches = Controls
+	if sim.CoOp {
+		game.Player2 = controls.NewController(Player2Controls, gameWidth, gameHeight)
 	}
 
 	go NewGame(game)
 
-	if err := ebiten.RunGame(game); err != nil {
+	err := ebiten.RunGame(game)
+	if *recordFile != "" && peer == nil { // replays don't know about versus yet
+		saveReplay(*recordFile, game.Recording)
+	}
+	if peer != nil {
+		peer.Close()
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
 }
 
 // NewGame sets up a new game object with default states and game objects
 func NewGame(game *Game) {
-	earth := &Earth{
-		Object:   NewObject(("assets/earth.png")),
-		Center:   image.Point{game.Width / 2, game.Height / 2},
-		Impacted: false,
+	game.Earth = &Earth{
+		Object: NewObject(("assets/earth.png")),
 	}
-	game.Earth = earth
 
 	explosion := &Explosion{
-		Object:    NewObjectFromImage(loadImage("assets/explosion.png")),
-		Frame:     1,
-		Exploding: false,
-		Done:      false,
+		Object: NewObjectFromImage(loadImage("assets/explosion.png")),
 	}
 	explosion.Radius = float64(explosion.Image.Bounds().Dy() / 2)
-	game.Crosshair = &Crosshair{
-		Object:    NewObject(("assets/crosshair.png")),
-		Explosion: explosion,
-	}
-
 	game.Moon = &Moon{
 		Object: NewObject("assets/moon.png"),
 		Turret: &Turret{
 			Object: NewObject("assets/turret.png"),
-			Angle:  0,
 		},
 	}
 
+	asteroids := &Asteroids{
+		Object:    NewObject("assets/asteroid.png"),
+		Explosion: explosion,
+	}
+
 	gotext := NewObject("assets/gameover.png")
 	gotext.Op.GeoM.Translate(
 		float64(game.Width/2-gotext.Image.Bounds().Dx()/2),
@@ -119,173 +187,169 @@ func NewGame(game *Game) {
 	)
 	game.GOText = gotext
 
-	entities := []Entity{
-		Asteroids{},
-		game.Moon,
-		game.Earth,
-		game.Crosshair,
+	entities := entity.New()
+	entities.Add(asteroids)
+	entities.Add(&PowerUps{FontFace: game.FontFace})
+	entities.Add(game.Moon)
+	entities.Add(game.Earth)
+	for i := range game.World.Crosshairs {
+		crosshair := &Crosshair{
+			Object:    NewObject(("assets/crosshair.png")),
+			Explosion: explosion,
+			Player:    i,
+		}
+		if i == 0 {
+			game.Crosshair = crosshair
+		}
+		entities.Add(crosshair)
 	}
 	game.Entities = entities
 
-	game.Loading = false
+	close(game.Loaded)
 }
 
-// NewAsteroids makes a fresh set of asteroids
-func NewAsteroids(earthRadius float64, howMany int) Asteroids {
-	asteroids := make(Asteroids, 0, howMany)
-	asteroidImage := loadImage("assets/asteroid.png")
-	explosionImage := loadImage("assets/explosion.png")
-	for i := 0; i < howMany; i++ {
-		explosion := &Explosion{
-			Object:    NewObjectFromImage(explosionImage),
-			Frame:     1,
-			Exploding: false,
-			Done:      false,
-		}
-		explosion.Radius = float64(explosion.Image.Bounds().Dy() / 2)
-
-		edgeOfScreenOffset := earthRadius * EdgeOfScreenOffset
-		distance := rand.Float64() * earthRadius * float64(howMany) / DistanceVariance
-		asteroids = append(asteroids, &Asteroid{
-			Object:    NewObjectFromImage(asteroidImage),
-			Angle:     rand.Float64() * math.Pi * 2,
-			Distance:  edgeOfScreenOffset + distance,
-			Explosion: explosion,
-			Alive:     true,
-			Impacting: false,
-		})
-	}
-
-	return asteroids
+// An Updater is anything that updates itself from the game world
+type Updater interface {
+	Update(*Game)
 }
 
-// An Entity represents anything that can update itself in the game and draw
-// itself to the main screen
-type Entity interface {
-	Update(*Game)
-	Draw(*ebiten.Image)
+// A Renderable is anything that draws itself to the main screen, in its
+// layer if it has one, with anything moving drawn between where it was at
+// the start of the tick and where it is now, see Game.Between
+type Renderable interface {
+	Draw(screen *ebiten.Image, between float64)
 }
 
-// Game represents the main game state
+// Game adapts the simulated game World to ebiten, feeding it input and
+// rendering its state
 type Game struct {
-	Width      int
-	Height     int
-	Loading    bool
-	FontFace   font.Face
-	Rotation   float64
-	Count      int
-	Wave       int
-	HowMany    int
-	Moon       *Moon
-	Earth      *Earth
-	Asteroids  Asteroids
-	GameOver   bool
-	Breathless bool // when you need a break between waves
-	Crosshair  *Crosshair
-	GOText     *Object
-	Entities   []Entity
-	Sounds     *Sounds
+	Width     int
+	Height    int
+	Loaded    chan struct{} // closed once NewGame is done loading
+	FontFace  font.Face
+	World     *sim.World
+	Controls  *controls.Controller
+	Player2   *controls.Controller // the second player's controls, in co-op
+	Moon      *Moon
+	Earth     *Earth
+	Crosshair *Crosshair
+	GOText    *Object
+	Entities  *entity.Registry
+	Sounds    *Sounds
+	Replay    *sim.Replay    // game being played back instead of live input
+	Recording *sim.Replay    // every tick played so far
+	Peer      *lockstep.Peer // the other player, in versus
+	ticked    time.Time      // when the last tick was played
 }
 
 // Update calculates game logic
 func (g *Game) Update() error {
+	state := g.World.State
 
-	// Pressing Esc any time quits immediately
-	if ebiten.IsKeyPressed(ebiten.KeyEscape) {
-		return errors.New("game quit by player")
+	// Skip updating while the game is loading
+	if g.World.State == sim.Loading {
+		select {
+		case <-g.Loaded:
+			g.World.Enter(sim.Title)
+		default:
+			return nil
+		}
 	}
 
+	// F toggles fullscreen any time, it's also in the settings menu
 	if inpututil.IsKeyJustPressed(ebiten.KeyF) {
-		if ebiten.IsFullscreen() {
-			ebiten.SetFullscreen(false)
-		} else {
-			ebiten.SetFullscreen(true)
-		}
+		g.World.Options.Fullscreen = !g.World.Options.Fullscreen
+		g.World.SettingsMenu.Label(g.World.Options)
 	}
 
-	// Skip updating while the game is loading
-	if g.Loading {
-		return nil
+	input := g.input()
+	if g.Peer != nil {
+		var err error
+		if input, err = g.Peer.Step(input, g.World.Checksum()); err != nil {
+			return fmt.Errorf("versus game with the %v stopped: %w", g.Peer.Role, err)
+		}
 	}
+	g.Recording.Record(input)
+	g.World.Update(input)
+	g.ticked = time.Now()
 
-	// Impact logic
-	if g.Asteroids.Alive() && g.Asteroids.Impacting() {
-		g.Earth.Impacted = true
+	if ebiten.IsFullscreen() != g.World.Options.Fullscreen {
+		ebiten.SetFullscreen(g.World.Options.Fullscreen)
 	}
 
-	// Game over
-	if g.Earth.Impacted {
-		if g.Asteroids.Alive() {
-			for _, v := range g.Asteroids {
-				v.Explosion.Exploding = true
-			}
-		} else if !g.GameOver {
-			g.GameOver = true
-			log.Println("game over")
-			g.Sounds.ExplsnLo.Rewind()
-			g.Sounds.ExplsnLo.Play()
-			g.Breathless = true
-			takeABreath := time.NewTimer(time.Second)
-			go func() {
-				log.Println("waiting")
-				<-takeABreath.C
-				g.Breathless = false
-			}()
-		}
+	// Sound starts with the first wave
+	if g.World.Wave > 0 && g.Sounds == nil {
+		g.Sounds = NewSounds()
 	}
+	g.Sounds.Update(g.World)
 
-	// Next wave
-	if !g.GameOver && !g.Asteroids.Alive() && !g.Breathless && g.Wave > 0 {
-		log.Println("wave passed")
-		g.Wave++
-		g.Breathless = true
-		takeABreath := time.NewTimer(time.Second * time.Duration(TimeBetweenWaves))
-		go func() {
-			log.Println("waiting")
-			<-takeABreath.C
-			g.HowMany *= WaveMultiplier
-			g.Restart()
-			g.Breathless = false // needs to come after restart
-		}()
+	// Keep new high scores, but not ones from watching a replay, and log how
+	// the game's going, which the game world leaves to us so that playing it
+	// doesn't allocate
+	if g.World.State != state {
+		log.Printf("%v -> %v\n", state, g.World.State)
 	}
-
-	// Global rotation for orbiting bodies
-	g.Rotation = g.Rotation - RotationSpeed
-
-	// Update object positions
-	for _, v := range g.Entities {
-		v.Update(g)
+	for _, e := range g.World.Events {
+		switch {
+		case e == sim.NewHighScore && g.Replay == nil:
+			saveHighScores(g.World.HighScores)
+		case e == sim.WaveStarted:
+			log.Printf("new wave: %d\n", g.World.HowMany)
+		}
 	}
 
-	// On wave zero, click to start the game
-	if g.Wave == 0 && clicked() {
-		g.Wave++
-		g.Sounds = NewSounds()
-		g.Restart()
-	}
+	// Update object positions, and forget anything that's done
+	entity.Each(g.Entities, func(_ entity.ID, v Updater) {
+		v.Update(g)
+	})
+	g.Entities.Expire()
 
-	// Game restart
-	if g.GameOver && clicked() && !g.Breathless {
-		g.Restart()
+	for _, e := range g.World.Events {
+		if e == sim.Quit {
+			return errors.New("game quit by player")
+		}
 	}
 
 	return nil
 }
 
-// Restart starts a new game with states reset
-func (g *Game) Restart() {
-	log.Printf("new wave: %d\n", g.HowMany)
-	g.Count = g.HowMany
-	g.Asteroids = NewAsteroids(g.Earth.Radius, g.HowMany)
-	g.Entities[0] = g.Asteroids
-	g.Earth.Impacted = false
-	g.GameOver = false
+// Between is how far through the current tick it is, from 0 to 1, so that
+// moving things are drawn smoothly however often the screen refreshes,
+// which is always a tick behind the game world
+func (g *Game) Between() float64 {
+	return min(1, time.Since(g.ticked).Seconds()*sim.TPS)
+}
+
+// input is what the player is doing this tick, or what they did if a replay
+// is being played back, the player takes over when the replay runs out
+func (g *Game) input() sim.Input {
+	if tick := len(g.Recording.Inputs); g.Replay != nil {
+		if tick < len(g.Replay.Inputs) {
+			return g.Replay.Inputs[tick]
+		}
+		log.Printf("replay finished on wave %d with %d asteroids left\n", g.World.Wave, g.World.Count)
+		g.Replay = nil
+	}
+	input := g.Controls.Input()
+	if g.Player2 != nil {
+		// Either player can pause and use the menus
+		second := g.Player2.Input()
+		input.Player2 = sim.Aim{Cursor: second.Cursor, Clicked: second.Clicked}
+		input.Pause = input.Pause || second.Pause
+		input.Up = input.Up || second.Up
+		input.Down = input.Down || second.Down
+		input.Confirm = input.Confirm || second.Confirm
+	}
+	if menu := g.World.Menu(); menu != nil && g.Controls.Pointed {
+		input.Choice = g.menuItemAt(menu, input.Cursor)
+	}
+	return input
 }
 
 // Draw handles rendering the sprites
 func (g *Game) Draw(screen *ebiten.Image) {
 
-	if g.Loading {
+	if g.World.State == sim.Loading {
 		loadText := "LOADING..."
 		loadTextF, _ := font.BoundString(g.FontFace, loadText)
 		loadTextW := (loadTextF.Max.X - loadTextF.Min.X).Ceil() / 2
@@ -293,7 +357,7 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		text.Draw(screen, loadText, g.FontFace, g.Width/2-loadTextW, g.Height/2-loadTextH, color.White)
 		return
 	}
-	if !g.Loading && g.Wave == 0 {
+	if g.World.State == sim.Title {
 		startText := "CLICK TO START"
 		startTextF, _ := font.BoundString(g.FontFace, startText)
 		startTextW := (startTextF.Max.X - startTextF.Min.X).Ceil() / 2
@@ -314,14 +378,32 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		titleTextW := (titleTextF.Max.X - titleTextF.Min.X).Ceil() / 2
 		titleTextH := (titleTextF.Max.Y - titleTextF.Min.Y).Ceil() * 2
 		text.Draw(screen, titleText, g.FontFace, g.Width/2-titleTextW, g.Height-titleTextH*4, color.White)
+		for i, v := range g.World.HighScores {
+			if i == 5 { // only the top few fit above the Earth
+				break
+			}
+			scoreText := fmt.Sprintf("%d. %6d WAVE %d", i+1, v.Score, v.Wave)
+			scoreTextF, _ := font.BoundString(g.FontFace, scoreText)
+			scoreTextW := (scoreTextF.Max.X - scoreTextF.Min.X).Ceil() / 2
+			scoreTextH := (scoreTextF.Max.Y - scoreTextF.Min.Y).Ceil() * 3 / 2
+			text.Draw(screen, scoreText, g.FontFace, g.Width/2-scoreTextW, startTextH*2+scoreTextH*i, color.White)
+		}
 	}
 
 	// Draw game objects
-	for _, v := range g.Entities {
-		v.Draw(screen)
+	between := g.Between()
+	entity.Layers(g.Entities, func(_ entity.ID, v Renderable) {
+		v.Draw(screen, between)
+	})
+
+	// Show where the attacker is launching from
+	if g.World.Versus && g.World.State == sim.Playing {
+		launch := g.World.Input.Player2.Cursor
+		vector.StrokeCircle(screen, float32(launch.X), float32(launch.Y), 16, 3, PlayerColours[1], true)
 	}
 
-	if g.GameOver {
+	state := g.World.Behind()
+	if state == sim.GameOver {
 		screen.DrawImage(g.GOText.Image, g.GOText.Op)
 	}
 
@@ -330,30 +412,90 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	f, _ := font.BoundString(g.FontFace, "00")
 	h := (f.Max.Y - f.Min.Y).Ceil() * 2
 	w := (f.Max.X - f.Min.X).Ceil() + padding
-	text.Draw(screen, strconv.Itoa(g.Count), g.FontFace, padding, h, color.White)
-	text.Draw(screen, strconv.Itoa(g.Wave), g.FontFace, g.Width-w, h, color.White)
-	if g.Crosshair.CoolingDown && !g.Breathless { // TODO: this should be in Crosshair.Draw()
-		missText := "MISSED: COOLING DOWN!"
-		missTextF, _ := font.BoundString(g.FontFace, missText)
-		missTextW := (missTextF.Max.X - missTextF.Min.X).Ceil() / 2
-		text.Draw(screen, missText, g.FontFace, g.Width/2-missTextW, h, color.White)
-	}
-	if !g.GameOver && g.Breathless {
-		tryAgain := fmt.Sprintf("WAVE %d", g.Wave)
+	text.Draw(screen, strconv.Itoa(g.World.Count), g.FontFace, padding, h, color.White)
+	text.Draw(screen, strconv.Itoa(g.World.Score), g.FontFace, padding, h*2, color.White)
+	text.Draw(screen, strconv.Itoa(g.World.Wave), g.FontFace, g.Width-w, h, color.White)
+	if len(g.World.Crosshairs) > 1 {
+		for i, c := range g.World.Crosshairs {
+			scoreText := fmt.Sprintf("P%d %d", i+1, c.Score)
+			text.Draw(screen, scoreText, g.FontFace, padding, h*(3+i), PlayerColours[i])
+		}
+	}
+	line := 2
+	for p, ticks := range g.World.Effects {
+		if ticks == 0 {
+			continue
+		}
+		effectText := fmt.Sprintf("%s %d", sim.Power(p), ticks/sim.TPS+1)
+		effectF, _ := font.BoundString(g.FontFace, effectText)
+		effectW := (effectF.Max.X - effectF.Min.X).Ceil() + padding
+		text.Draw(screen, effectText, g.FontFace, g.Width-effectW, h*line, PowerColours[p])
+		line++
+	}
+	g.drawHealthBar(screen, padding)
+	if sim.MoonSteering {
+		g.drawEnergyBar(screen, padding)
+	}
+	for i, c := range g.World.Crosshairs {
+		if c.CoolingDown && state == sim.Playing { // TODO: this should be in Crosshair.Draw()
+			missText := "MISSED: COOLING DOWN!"
+			missColour := color.Color(color.White)
+			if len(g.World.Crosshairs) > 1 {
+				missText = fmt.Sprintf("P%d %s", i+1, missText)
+				missColour = PlayerColours[i]
+			}
+			missTextF, _ := font.BoundString(g.FontFace, missText)
+			missTextW := (missTextF.Max.X - missTextF.Min.X).Ceil() / 2
+			text.Draw(screen, missText, g.FontFace, g.Width/2-missTextW, h*(1+i), missColour)
+		}
+	}
+	if state == sim.WaveIntermission {
+		tryAgain := fmt.Sprintf("WAVE %d", g.World.Wave)
 		tryAgainF, _ := font.BoundString(g.FontFace, tryAgain)
 		tryAgainW := (tryAgainF.Max.X - tryAgainF.Min.X).Ceil() / 2
 		text.Draw(screen, tryAgain, g.FontFace, g.Width/2-tryAgainW, h, color.White)
 	}
-	if g.GameOver && !g.Breathless {
+	if state == sim.GameOver && g.World.InStateFor(time.Second) {
 		tryAgain := "CLICK TO TRY AGAIN"
 		tryAgainF, _ := font.BoundString(g.FontFace, tryAgain)
 		tryAgainW := (tryAgainF.Max.X - tryAgainF.Min.X).Ceil() / 2
 		text.Draw(screen, tryAgain, g.FontFace, g.Width/2-tryAgainW, h, color.White)
 	}
 
+	if menu := g.World.Menu(); menu != nil {
+		g.drawMenu(screen, menu)
+	}
+
 	// debug(screen, g)
 }
 
+// drawHealthBar shows how much health the Earth has left at the bottom of
+// the screen, with its shield on top
+func (g *Game) drawHealthBar(screen *ebiten.Image, padding int) {
+	width, height := float32(g.Width/3), float32(padding/2)
+	x, y := float32(g.Width)/2-width/2, float32(g.Height-padding)-height
+
+	health := float32(g.World.Earth.Health / sim.EarthHealth)
+	vector.DrawFilledRect(screen, x, y, width, height, color.RGBA{60, 0, 0, 255}, false)
+	vector.DrawFilledRect(screen, x, y, width*health, height, color.RGBA{uint8(255 * (1 - health)), uint8(200 * health), 0, 255}, false)
+
+	if sim.EarthShield > 0 {
+		shield := float32(g.World.Earth.Shield / sim.EarthShield)
+		vector.DrawFilledRect(screen, x, y-height/2, width*shield, height/3, color.RGBA{80, 140, 255, 255}, false)
+	}
+}
+
+// drawEnergyBar shows how much thrust the Moon has left for steering, under
+// the health bar
+func (g *Game) drawEnergyBar(screen *ebiten.Image, padding int) {
+	width, height := float32(g.Width/3), float32(padding/4)
+	x, y := float32(g.Width)/2-width/2, float32(g.Height-padding)+height
+
+	energy := float32(g.World.Moon.Energy / sim.MoonEnergy)
+	vector.DrawFilledRect(screen, x, y, width, height, color.RGBA{40, 40, 40, 255}, false)
+	vector.DrawFilledRect(screen, x, y, width*energy, height, color.RGBA{230, 200, 60, 255}, false)
+}
+
 // Layout is hardcoded for now, may be made dynamic in future
 func (g *Game) Layout(outsideWidth int, outsideHeight int) (screenWidth int, screenHeight int) {
 	return g.Width, g.Height
@@ -363,15 +505,244 @@ func applyConfigs() {
 	cfg, err := ini.Load("lunar-defence.ini")
 	log.Println(err)
 	if err == nil {
-		HowManyStart, _ = cfg.Section("").Key("HowManyStart").Int()
-		EdgeOfScreenOffset, _ = cfg.Section("").Key("EdgeOfScreenOffset").Float64()
-		DistanceVariance, _ = cfg.Section("").Key("DistanceVariance").Float64()
-		TimeBetweenWaves, _ = cfg.Section("").Key("TimeBetweenWaves").Int()
-		WaveMultiplier, _ = cfg.Section("").Key("WaveMultiplier").Int()
-		RotationSpeed, _ = cfg.Section("").Key("RotationSpeed").Float64()
-		MoonOrbitRatio, _ = cfg.Section("").Key("MoonOrbitRatio").Float64()
-		MoonOrbitDistance, _ = cfg.Section("").Key("MoonOrbitDistance").Float64()
-		AsteroidSpinRatio, _ = cfg.Section("").Key("AsteroidSpinRatio").Float64()
+		sim.HowManyStart, _ = cfg.Section("").Key("HowManyStart").Int()
+		sim.EdgeOfScreenOffset, _ = cfg.Section("").Key("EdgeOfScreenOffset").Float64()
+		sim.TimeBetweenWaves, _ = cfg.Section("").Key("TimeBetweenWaves").Int()
+		sim.WaveMultiplier, _ = cfg.Section("").Key("WaveMultiplier").Int()
+		sim.RotationSpeed, _ = cfg.Section("").Key("RotationSpeed").Float64()
+		sim.MoonOrbitRatio, _ = cfg.Section("").Key("MoonOrbitRatio").Float64()
+		sim.MoonOrbitDistance, _ = cfg.Section("").Key("MoonOrbitDistance").Float64()
+		sim.AsteroidSpinRatio, _ = cfg.Section("").Key("AsteroidSpinRatio").Float64()
+		sim.LaserWidth = cfg.Section("").Key("LaserWidth").MustFloat64(sim.LaserWidth)
+		Seed, _ = cfg.Section("").Key("Seed").Int64()
+		sim.PointsShot = cfg.Section("").Key("PointsShot").MustInt(sim.PointsShot)
+		sim.PointsRammed = cfg.Section("").Key("PointsRammed").MustInt(sim.PointsRammed)
+		sim.PointsMissed = cfg.Section("").Key("PointsMissed").MustInt(sim.PointsMissed)
+		sim.MaxOnScreen = cfg.Section("").Key("MaxOnScreen").MustInt(sim.MaxOnScreen)
+		sim.BurstSize = cfg.Section("").Key("BurstSize").MustInt(sim.BurstSize)
+		sim.BurstGap = cfg.Section("").Key("BurstGap").MustFloat64(sim.BurstGap)
+		sim.Lull = cfg.Section("").Key("Lull").MustFloat64(sim.Lull)
+		sim.BurstSpread = cfg.Section("").Key("BurstSpread").MustFloat64(sim.BurstSpread)
+		sim.EarthGravity = cfg.Section("").Key("EarthGravity").MustFloat64(sim.EarthGravity)
+		sim.MoonGravity = cfg.Section("").Key("MoonGravity").MustFloat64(sim.MoonGravity)
+		sim.SpiralPitch = cfg.Section("").Key("SpiralPitch").MustFloat64(sim.SpiralPitch)
+		sim.CurveAim = cfg.Section("").Key("CurveAim").MustFloat64(sim.CurveAim)
+		sim.Leash = cfg.Section("").Key("Leash").MustFloat64(sim.Leash)
+		sim.PowerUpChance = cfg.Section("").Key("PowerUpChance").MustFloat64(sim.PowerUpChance)
+		sim.PowerUpDuration = cfg.Section("").Key("PowerUpDuration").MustFloat64(sim.PowerUpDuration)
+		sim.PowerUpSpeed = cfg.Section("").Key("PowerUpSpeed").MustFloat64(sim.PowerUpSpeed)
+		sim.RapidFireRatio = cfg.Section("").Key("RapidFireRatio").MustFloat64(sim.RapidFireRatio)
+		sim.WideLaserRatio = cfg.Section("").Key("WideLaserRatio").MustFloat64(sim.WideLaserRatio)
+		sim.SlowMotionRatio = cfg.Section("").Key("SlowMotionRatio").MustFloat64(sim.SlowMotionRatio)
+		sim.EarthHealth = cfg.Section("").Key("EarthHealth").MustFloat64(sim.EarthHealth)
+		sim.EarthShield = cfg.Section("").Key("EarthShield").MustFloat64(sim.EarthShield)
+		sim.ShieldRegen = cfg.Section("").Key("ShieldRegen").MustFloat64(sim.ShieldRegen)
+		sim.ShieldRegenDelay = cfg.Section("").Key("ShieldRegenDelay").MustFloat64(sim.ShieldRegenDelay)
+		sim.ImpactDamage = cfg.Section("").Key("ImpactDamage").MustFloat64(sim.ImpactDamage)
+		sim.CreditsPerAsteroid = cfg.Section("").Key("CreditsPerAsteroid").MustInt(sim.CreditsPerAsteroid)
+		sim.CooldownUpgrade = cfg.Section("").Key("CooldownUpgrade").MustFloat64(sim.CooldownUpgrade)
+		sim.ReachUpgrade = cfg.Section("").Key("ReachUpgrade").MustFloat64(sim.ReachUpgrade)
+		sim.MultiShotRange = cfg.Section("").Key("MultiShotRange").MustFloat64(sim.MultiShotRange)
+		sim.OrbitUpgrade = cfg.Section("").Key("OrbitUpgrade").MustFloat64(sim.OrbitUpgrade)
+		sim.MoonKnockback = cfg.Section("").Key("MoonKnockback").MustBool(sim.MoonKnockback)
+		sim.MoonMass = cfg.Section("").Key("MoonMass").MustFloat64(sim.MoonMass)
+		sim.AsteroidMass = cfg.Section("").Key("AsteroidMass").MustFloat64(sim.AsteroidMass)
+		sim.Restitution = cfg.Section("").Key("Restitution").MustFloat64(sim.Restitution)
+		sim.KnockTime = cfg.Section("").Key("KnockTime").MustFloat64(sim.KnockTime)
+		sim.MoonSteering = cfg.Section("").Key("MoonSteering").MustBool(sim.MoonSteering)
+		sim.OrbitAcceleration = cfg.Section("").Key("OrbitAcceleration").MustFloat64(sim.OrbitAcceleration)
+		sim.MaxOrbitRatio = cfg.Section("").Key("MaxOrbitRatio").MustFloat64(sim.MaxOrbitRatio)
+		sim.MoonEnergy = cfg.Section("").Key("MoonEnergy").MustFloat64(sim.MoonEnergy)
+		sim.ThrustCost = cfg.Section("").Key("ThrustCost").MustFloat64(sim.ThrustCost)
+		sim.EnergyRegen = cfg.Section("").Key("EnergyRegen").MustFloat64(sim.EnergyRegen)
+		sim.ExtraMoonWaves = cfg.Section("").Key("ExtraMoonWaves").MustInt(sim.ExtraMoonWaves)
+		sim.MaxMoons = cfg.Section("").Key("MaxMoons").MustInt(sim.MaxMoons)
+		sim.MoonSpacing = cfg.Section("").Key("MoonSpacing").MustFloat64(sim.MoonSpacing)
+		sim.CoOp = cfg.Section("").Key("CoOp").MustBool(sim.CoOp)
+		sim.LaunchCooldown = cfg.Section("").Key("LaunchCooldown").MustFloat64(sim.LaunchCooldown)
+		sim.HoldTime = cfg.Section("").Key("HoldTime").MustFloat64(sim.HoldTime)
+		lockstep.Delay = cfg.Section("").Key("InputDelay").MustInt(lockstep.Delay)
+		sim.GridCell = cfg.Section("").Key("GridCell").MustFloat64(sim.GridCell)
+
+		for _, kind := range sim.AsteroidTypes {
+			if !cfg.HasSection("Asteroid." + kind.Name) {
+				continue
+			}
+			section := cfg.Section("Asteroid." + kind.Name)
+			kind.Scale = section.Key("Scale").MustFloat64(kind.Scale)
+			kind.Speed = section.Key("Speed").MustFloat64(kind.Speed)
+			kind.HitPoints = section.Key("HitPoints").MustInt(kind.HitPoints)
+			kind.Fragments = section.Key("Fragments").MustInt(kind.Fragments)
+			kind.Fragment = section.Key("Fragment").MustString(kind.Fragment)
+			kind.Weight = section.Key("Weight").MustInt(kind.Weight)
+			kind.FromWave = section.Key("FromWave").MustInt(kind.FromWave)
+			kind.Path, _ = sim.PathByName(section.Key("Path").In(kind.Path.String(), sim.Paths))
+		}
+
+		bindings := cfg.Section("Controls")
+		for _, action := range controls.Actions {
+			if !bindings.HasKey(action.String()) {
+				continue
+			}
+			binding, err := ParseBinding(bindings.Key(action.String()).String())
+			if err != nil {
+				log.Printf("error in %s controls: %v\n", action, err)
+				continue
+			}
+			Controls.Bindings[action] = binding
+		}
+		Controls.RightAim = bindings.Key("AimStick").In("Left", []string{"Left", "Right"}) == "Right"
+		controls.CrosshairSpeed = bindings.Key("CrosshairSpeed").MustFloat64(controls.CrosshairSpeed)
+		controls.StickDeadzone = bindings.Key("StickDeadzone").MustFloat64(controls.StickDeadzone)
+		if sim.CoOp {
+			Controls.Gamepad = bindings.Key("Player1Gamepad").MustInt(0)
+			if Controls.Gamepad == 0 {
+				Controls.Gamepad = -1 // just the mouse and keyboard
+			}
+			Player2Controls.Gamepad = bindings.Key("Player2Gamepad").MustInt(Player2Controls.Gamepad)
+			Player2Controls.RightAim = Controls.RightAim
+		}
+	}
+}
+
+// connect hosts or joins a versus game, if asked to, it waits until the other
+// player is there, the host plays with gameplay and so does the attacker
+func connect(hostAddr, joinAddr string, gameplay *sim.Gameplay) *lockstep.Peer {
+	var peer *lockstep.Peer
+	var err error
+	switch {
+	case hostAddr != "":
+		l, listenErr := net.Listen("tcp", hostAddr)
+		if listenErr != nil {
+			log.Fatalf("error hosting versus game: %v\n", listenErr)
+		}
+		defer l.Close()
+		log.Printf("waiting for an attacker to join on %s\n", l.Addr())
+		peer, err = lockstep.Host(l, Seed, gameplay)
+	case joinAddr != "":
+		log.Printf("joining versus game at %s\n", joinAddr)
+		peer, err = lockstep.Join(joinAddr)
+	default:
+		return nil
+	}
+	if err != nil {
+		log.Fatalf("error starting versus game: %v\n", err)
+	}
+	log.Printf("playing versus as the %v\n", peer.Role)
+	return peer
+}
+
+func loadReplay(name string) *sim.Replay {
+	file, err := os.Open(name)
+	if err != nil {
+		log.Fatalf("error opening replay %s: %v\n", name, err)
+	}
+	defer file.Close()
+
+	replay, err := sim.LoadReplay(file)
+	if err != nil {
+		log.Fatalf("error loading replay %s: %v\n", name, err)
+	}
+	return replay
+}
+
+// sameFile reports whether a and b are the same file, they aren't if either
+// doesn't exist
+func sameFile(a, b string) bool {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(aInfo, bInfo)
+}
+
+func loadWaves(name string) sim.Waves {
+	file, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Printf("no waves in %s, making them up\n", name)
+		return nil
+	} else if err != nil {
+		log.Fatalf("error opening waves %s: %v\n", name, err)
+	}
+	defer file.Close()
+
+	waves, err := sim.LoadWaves(file)
+	if err != nil {
+		log.Fatalf("error in waves %s: %v\n", name, err)
+	}
+	log.Printf("loaded %d waves from %s\n", len(waves), name)
+	return waves
+}
+
+func saveReplay(name string, replay *sim.Replay) {
+	file, err := os.Create(name)
+	if err != nil {
+		log.Printf("error creating replay %s: %v\n", name, err)
+		return
+	}
+	defer file.Close()
+
+	if err := replay.Save(file); err != nil {
+		log.Printf("error saving replay %s: %v\n", name, err)
+		return
+	}
+	log.Printf("saved replay %s\n", name)
+}
+
+// highScoresPath is where high scores are kept in the user's config directory
+func highScoresPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "lunar-defence", "highscores.json"), nil
+}
+
+func loadHighScores() sim.HighScores {
+	name, err := highScoresPath()
+	if err != nil {
+		log.Printf("error finding high scores: %v\n", err)
+		return nil
+	}
+	file, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		log.Printf("error opening high scores %s: %v\n", name, err)
+		return nil
+	}
+	defer file.Close()
+
+	highScores, err := sim.LoadHighScores(file)
+	if err != nil {
+		log.Printf("error loading high scores %s: %v\n", name, err)
+	}
+	return highScores
+}
+
+func saveHighScores(highScores sim.HighScores) {
+	name, err := highScoresPath()
+	if err != nil {
+		log.Printf("error finding high scores: %v\n", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		log.Printf("error making high scores directory: %v\n", err)
+		return
+	}
+	file, err := os.Create(name)
+	if err != nil {
+		log.Printf("error creating high scores %s: %v\n", name, err)
+		return
+	}
+	defer file.Close()
+
+	if err := highScores.Save(file); err != nil {
+		log.Printf("error saving high scores %s: %v\n", name, err)
 	}
 }
 
@@ -391,6 +762,7 @@ func loadFont() font.Face {
 	return fontface
 }
 
+// Sounds are all the sound effects and music used in the game
 type Sounds struct {
 	Laser     *audio.Player
 	ExplsnHi  *audio.Player
@@ -399,6 +771,7 @@ type Sounds struct {
 	Music     *audio.Player
 }
 
+// NewSounds loads all the game's sounds and starts playing the music
 func NewSounds() *Sounds {
 	sampleRate := 44100
 	audioConext := audio.NewContext(sampleRate)
@@ -419,6 +792,41 @@ func NewSounds() *Sounds {
 	}
 }
 
+// Update plays the sound effects for things that happened in the game world
+// and turns music and sound on and off according to the player's options
+func (s *Sounds) Update(w *sim.World) {
+	if s == nil {
+		return
+	}
+	if w.Options.Music && !s.Music.IsPlaying() {
+		s.Music.Play()
+	} else if !w.Options.Music && s.Music.IsPlaying() {
+		s.Music.Pause()
+	}
+	if !w.Options.Sound {
+		return
+	}
+	for _, e := range w.Events {
+		switch e {
+		case sim.LaserFired, sim.PowerUpCollected, sim.AsteroidLaunched:
+			s.Laser.Rewind()
+			s.Laser.Play()
+		case sim.ShotExplosion:
+			s.ExplsnMid.Rewind()
+			s.ExplsnMid.Play()
+		case sim.AsteroidDamaged, sim.AsteroidRammed, sim.AsteroidKnocked, sim.ShieldHit:
+			s.ExplsnHi.Rewind()
+			s.ExplsnHi.Play()
+		case sim.EarthHit:
+			s.ExplsnMid.Rewind()
+			s.ExplsnMid.Play()
+		case sim.EarthDestroyed:
+			s.ExplsnLo.Rewind()
+			s.ExplsnLo.Play()
+		}
+	}
+}
+
 func loadSound(name string, context *audio.Context) *audio.Player {
 	music := loadSoundFile(name, context)
 	audioPlayer, err := audio.NewPlayer(context, music)
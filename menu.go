@@ -0,0 +1,70 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/sinisterstuf/lunar-defence/sim"
+	"golang.org/x/image/font"
+)
+
+// menuLineHeight is how far apart menu items are drawn
+func (g *Game) menuLineHeight() int {
+	f, _ := font.BoundString(g.FontFace, "M")
+	return (f.Max.Y - f.Min.Y).Ceil() * 2
+}
+
+// menuTop is the baseline of the line above a menu's first item, which is
+// where its heading goes
+func (g *Game) menuTop(m *sim.Menu) int {
+	return g.Height/2 - g.menuLineHeight()*len(m.Items)/2
+}
+
+// menuItemAt returns which menu item is drawn at p counting from one, or zero
+// if there isn't one there
+func (g *Game) menuItemAt(m *sim.Menu, p image.Point) int {
+	// Rows are centred a little above each item's baseline, around the text
+	lineHeight := g.menuLineHeight()
+	y := p.Y - g.menuTop(m) + lineHeight*3/4
+	if y < lineHeight || y/lineHeight > len(m.Items) {
+		return 0
+	}
+	return y / lineHeight
+}
+
+// drawMenu darkens the game and draws the menu over it
+func (g *Game) drawMenu(screen *ebiten.Image, m *sim.Menu) {
+	ebitenutil.DrawRect(screen, 0, 0, float64(g.Width), float64(g.Height), color.RGBA{0, 0, 0, 192})
+
+	heading := "PAUSED"
+	switch g.World.State {
+	case sim.Settings:
+		heading = "SETTINGS"
+	case sim.WaveIntermission:
+		heading = fmt.Sprintf("UPGRADES - %d CREDITS", g.World.Credits)
+	}
+	lineHeight := g.menuLineHeight()
+	top := g.menuTop(m)
+	headingF, _ := font.BoundString(g.FontFace, heading)
+	headingW := (headingF.Max.X - headingF.Min.X).Ceil() / 2
+	text.Draw(screen, heading, g.FontFace, g.Width/2-headingW, top-lineHeight, color.White)
+
+	for i, item := range m.Items {
+		itemColor := color.RGBA{128, 128, 128, 255}
+		if i == m.Selected {
+			item = "> " + item + " <"
+			itemColor = color.RGBA{255, 255, 255, 255}
+		}
+		itemF, _ := font.BoundString(g.FontFace, item)
+		itemW := (itemF.Max.X - itemF.Min.X).Ceil() / 2
+		text.Draw(screen, item, g.FontFace, g.Width/2-itemW, top+lineHeight*(i+1), itemColor)
+	}
+}
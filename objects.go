@@ -9,32 +9,32 @@ import (
 	"image/color"
 	"image/png"
 	"log"
-	"math"
-	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/sinisterstuf/lunar-defence/sim"
+	"golang.org/x/image/font"
 )
 
-// An Object is something that can be seen and positioned in the game
+// Layers are the order things are drawn in, from the back to the front
+const (
+	AsteroidLayer = iota
+	PowerUpLayer
+	MoonLayer
+	EarthLayer
+	CrosshairLayer
+)
+
+// An Object is something that can be seen in the game, it is positioned
+// according to its counterpart in the simulated game world
 type Object struct {
 	Image  *ebiten.Image
 	Op     *ebiten.DrawImageOptions
-	Center image.Point
 	Radius float64
 }
 
-// Overlaps reports whether o and p have a non-empty intersection
-func (o *Object) Overlaps(p *Object) bool {
-	diff := o.Center.Sub(p.Center)
-	distance := math.Sqrt(math.Pow(float64(diff.X), 2) + math.Pow(float64(diff.Y), 2))
-	if distance <= o.Radius+p.Radius {
-		return true
-	}
-	return false
-}
-
 // NewObject makes a new game Object with fields calculated from the input image
 func NewObject(filename string) *Object {
 	img := loadImage(filename)
@@ -47,84 +47,61 @@ func NewObjectFromImage(img *ebiten.Image) *Object {
 	return &Object{
 		Image:  img,
 		Op:     &ebiten.DrawImageOptions{},
-		Center: image.Pt(0, 0),
 		Radius: float64(img.Bounds().Dx()) / 2,
 	}
 }
 
-// Moon is our moon, orbiting around the earth
+// Place re-translates the Object's GeoM so its image is drawn centred on
+// center and spun by angle
+func (o *Object) Place(center sim.Vec, angle float64) {
+	o.Op.GeoM.Reset()
+
+	// Spin
+	o.Op.GeoM.Translate(-o.Radius, -o.Radius)
+	o.Op.GeoM.Rotate(angle)
+	o.Op.GeoM.Translate(o.Radius, o.Radius)
+
+	// Reposition with image offset to center
+	o.Op.GeoM.Translate(center.X, center.Y)
+	o.Op.GeoM.Translate(-o.Radius, -o.Radius)
+}
+
+// Moon is our moon, orbiting around the earth, there can be several of them
 type Moon struct {
 	*Object
 	*Turret
+	Moons  sim.Moons
+	Firing *sim.Moon // the moon whose turret fires next
 }
 
-// Update recalculates moon position
-func (o Moon) Update(g *Game) {
-	t := g.Rotation / MoonOrbitRatio
-	d := g.Earth.Radius + o.Radius*MoonOrbitDistance
-
-	// Calculated centre for collision detection
-	x := (d) * math.Cos(t)
-	y := (d) * math.Sin(t)
-	o.Center = image.Pt(
-		int(x)+g.Width/2,
-		int(y)+g.Height/2,
-	)
+// Update takes the current moons from the game world
+func (o *Moon) Update(g *Game) {
+	o.Moons = g.World.Moons
+	o.Firing = g.World.Crosshair.Moon(g.World)
+}
 
-	// Spin the moon
-	// Re-translate GeoM
-	o.Op.GeoM.Reset()
-	o.Op.GeoM.Translate(-o.Radius, -o.Radius)
-	o.Op.GeoM.Rotate(t)
-	o.Op.GeoM.Translate(o.Radius, o.Radius)
-	o.Op.GeoM.Translate(float64(o.Center.X), float64(o.Center.Y))
-	o.Op.GeoM.Translate(-o.Radius, -o.Radius)
+// Layer is where moons are drawn, behind the Earth
+func (o *Moon) Layer() int { return MoonLayer }
+
+// Draw renders all the moons to the screen, with the turrets that won't fire
+// next dimmed when there's more than one
+func (o *Moon) Draw(screen *ebiten.Image, between float64) {
+	for _, m := range o.Moons {
+		o.Place(m.Between(between), m.Orbit())
+		screen.DrawImage(o.Image, o.Op)
 
-	for _, v := range g.Asteroids {
-		if o.Overlaps(v.Object) && v.Alive && !v.Explosion.Exploding {
-			v.Explosion.Exploding = true
-			g.Sounds.ExplsnHi.Rewind()
-			g.Sounds.ExplsnHi.Play()
-			g.Count--
+		o.Turret.Place(m.Turret.Between(between), m.Turret.Angle)
+		o.Turret.Op.ColorScale.Reset()
+		if len(o.Moons) > 1 && m != o.Firing {
+			o.Turret.Op.ColorScale.Scale(0.5, 0.5, 0.5, 1)
 		}
+		o.Turret.Draw(screen)
 	}
-
-	o.Turret.Update(g)
-}
-
-// Draw renders a Moon to the screen
-func (o *Moon) Draw(screen *ebiten.Image) {
-	screen.DrawImage(o.Image, o.Op)
-	o.Turret.Draw(screen)
 }
 
 // A Turret is a weapon on the moon that shoots lasers
 type Turret struct {
 	*Object
-	Angle float64
-}
-
-// Update calculates Turrent game logic
-func (o *Turret) Update(g *Game) {
-	o.Center = g.Moon.Center
-
-	// Calculate rotation towards crosshair if it's not cooling down
-	if !g.Crosshair.CoolingDown {
-		adjacent := float64(o.Center.X - g.Crosshair.Center.X)
-		opposite := float64(o.Center.Y - g.Crosshair.Center.Y)
-		o.Angle = math.Atan2(opposite, adjacent)
-	}
-
-	o.Op.GeoM.Reset()
-
-	// Spin
-	o.Op.GeoM.Translate(-o.Radius, -o.Radius)
-	o.Op.GeoM.Rotate(o.Angle)
-	o.Op.GeoM.Translate(o.Radius, o.Radius)
-
-	// Reposition
-	o.Op.GeoM.Translate(float64(o.Center.X), float64(o.Center.Y))
-	o.Op.GeoM.Translate(-o.Radius, -o.Radius)
 }
 
 // Draw renders a Turret to the screen
@@ -135,248 +112,215 @@ func (o *Turret) Draw(screen *ebiten.Image) {
 // Earth is the earth, our home planet
 type Earth struct {
 	*Object
-	Center   image.Point
 	Impacted bool
+	Health   float64 // how much health is left, from 0 to 1
+	Shield   float64 // how much shield is left, from 0 to 1
+	Center   sim.Vec
 }
 
 // Update repositions Earth
-func (o Earth) Update(g *Game) {
-	o.Op.GeoM.Reset()
-	o.Op.GeoM.Translate(
-		-o.Radius,
-		-o.Radius,
-	)
-	o.Op.GeoM.Rotate(g.Rotation)
-	o.Op.GeoM.Translate(o.Pt())
-}
-
-// Draw renders a Earth to the screen
-func (o *Earth) Draw(screen *ebiten.Image) {
-	if !o.Impacted {
-		screen.DrawImage(o.Image, o.Op)
+func (o *Earth) Update(g *Game) {
+	earth := g.World.Earth
+	o.Impacted = earth.Impacted
+	o.Health = earth.Health / sim.EarthHealth
+	o.Shield = 0
+	if sim.EarthShield > 0 {
+		o.Shield = earth.Shield / sim.EarthShield
 	}
+	o.Center = earth.Center
+	o.Place(earth.Center, g.World.Rotation)
 }
 
-// Pt is a shortcut for the Earth's X and Y coordinates
-func (o Earth) Pt() (X, Y float64) {
-	return float64(o.Center.X), float64(o.Center.Y)
-}
-
-// Asteroid is an asteroid on impact course with the Earth
-type Asteroid struct {
-	*Object
-	Angle     float64
-	Distance  float64
-	Explosion *Explosion
-	Alive     bool
-	Impacting bool
-}
-
-// Update recalculates Asteroid position
-func (o *Asteroid) Update(g *Game) {
-	var RotationSpeed float64 = AsteroidSpinRatio
+// Layer is where the Earth is drawn
+func (o *Earth) Layer() int { return EarthLayer }
 
-	// Asteroid impacts earth
-	if o.Distance > 0 {
-		o.Distance = o.Distance - 1
-	} else if o.Alive {
-		o.Impacting = true
-		o.Explosion.Exploding = true
+// Draw renders a Earth to the screen, scorched more the more damaged it is
+// and with its shield around it
+func (o *Earth) Draw(screen *ebiten.Image, between float64) {
+	if o.Impacted {
+		return
 	}
 
-	// Calculated centre for collision detection
-	t := o.Angle
-	d := o.Distance + g.Earth.Radius
-	x := (d) * math.Cos(t)
-	y := (d) * math.Sin(t)
-	o.Center = image.Pt(
-		int(x)+g.Width/2,
-		int(y)+g.Height/2,
-	)
-
-	// Re-translate GeoM
-	o.Op.GeoM.Reset()
-
-	// Spin the asteroid
-	o.Op.GeoM.Translate(-o.Radius, -o.Radius)
-	o.Op.GeoM.Rotate(g.Rotation * RotationSpeed)
-	o.Op.GeoM.Translate(o.Radius, o.Radius)
-
-	// Move to newly calculated x, y with image offset to center
-	o.Op.GeoM.Translate(float64(o.Center.X), float64(o.Center.Y))
-	o.Op.GeoM.Translate(-o.Radius, -o.Radius)
+	o.Op.ColorScale.Reset()
+	switch {
+	case o.Health < 1.0/3:
+		o.Op.ColorScale.Scale(1, 0.45, 0.35, 1)
+	case o.Health < 2.0/3:
+		o.Op.ColorScale.Scale(1, 0.8, 0.6, 1)
+	}
+	screen.DrawImage(o.Image, o.Op)
 
-	// Handle Explosion
-	o.Explosion.Update(g, o.Center)
-	if o.Explosion.Done && o.Alive {
-		o.Alive = false
+	if o.Shield > 0 {
+		vector.StrokeCircle(
+			screen,
+			float32(o.Center.X), float32(o.Center.Y), float32(o.Radius+6),
+			4, color.RGBA{80, 140, 255, uint8(200 * o.Shield)}, true,
+		)
 	}
 }
 
-// Draw renders a Asteroid to the screen
-func (o *Asteroid) Draw(screen *ebiten.Image) {
-	if o.Alive {
-		screen.DrawImage(o.Image, o.Op)
-		o.Explosion.Draw(screen)
-	}
+// Asteroids draws all the asteroids in the game world with the same image
+type Asteroids struct {
+	*Object
+	Explosion *Explosion
+	Asteroids sim.Asteroids
+	Spin      float64
 }
 
-// Asteroids are multiple of a single Asteroid
-type Asteroids []*Asteroid
+// Update takes the current asteroids from the game world
+func (o *Asteroids) Update(g *Game) {
+	o.Asteroids = g.World.Asteroids
+	o.Spin = g.World.Rotation * sim.AsteroidSpinRatio
+}
 
-// Update updates all the Asteroids
-func (as Asteroids) Update(g *Game) {
-	for _, v := range as {
-		v.Update(g)
+// Layer is where Asteroids are drawn, behind everything else
+func (o *Asteroids) Layer() int { return AsteroidLayer }
+
+// Draw renders all the living Asteroids to the screen, sized and coloured by
+// their type and darker the more damaged they are
+func (o *Asteroids) Draw(screen *ebiten.Image, between float64) {
+	for _, v := range o.Asteroids {
+		if v.Alive && !v.Waiting {
+			at := v.Between(between)
+			o.Op.GeoM.Reset()
+			o.Op.GeoM.Translate(-o.Radius, -o.Radius)
+			o.Op.GeoM.Scale(v.Type.Scale, v.Type.Scale)
+			o.Op.GeoM.Rotate(o.Spin)
+			o.Op.GeoM.Translate(at.X, at.Y)
+
+			health := float32(v.HitPoints) / float32(v.Type.HitPoints)
+			o.Op.ColorScale.Reset()
+			o.Op.ColorScale.ScaleWithColor(v.Type.Tint)
+			o.Op.ColorScale.Scale(0.5+health/2, 0.5+health/2, 0.5+health/2, 1)
+
+			screen.DrawImage(o.Image, o.Op)
+			o.Explosion.Draw(screen, v.Explosion, between)
+		}
 	}
+}
 
-	// TODO: delete dead asteroids
-	// append(s[:index], s[index+1:]...)
-
+// PowerColours are what colour each kind of power-up is drawn in
+var PowerColours = [sim.Powers]color.RGBA{
+	sim.RapidFire:  {255, 200, 0, 255},
+	sim.NoCooldown: {0, 220, 255, 255},
+	sim.WideLaser:  {255, 60, 60, 255},
+	sim.Shield:     {80, 140, 255, 255},
+	sim.SlowMotion: {160, 255, 120, 255},
+	sim.SmartBomb:  {255, 255, 255, 255},
 }
 
-// Draw updates all the Asteroids
-func (as Asteroids) Draw(screen *ebiten.Image) {
-	for _, v := range as {
-		v.Draw(screen)
-	}
+// PowerUps draws all the power-ups in the game world as coloured circles
+// marked with the first letter of their power
+type PowerUps struct {
+	FontFace font.Face
+	PowerUps sim.PowerUps
 }
 
-// Alive returns true if any Asteroids are alive
-func (as Asteroids) Alive() bool {
-	for _, v := range as {
-		if v.Alive {
-			return true
-		}
-	}
-	return false
+// Update takes the current power-ups from the game world
+func (o *PowerUps) Update(g *Game) {
+	o.PowerUps = g.World.PowerUps
 }
 
-// Impacting returns true if any Asteroids are impacting
-func (as Asteroids) Impacting() bool {
-	for _, v := range as {
-		if v.Impacting {
-			return true
-		}
+// Layer is where PowerUps are drawn
+func (o *PowerUps) Layer() int { return PowerUpLayer }
+
+// Draw renders all the PowerUps to the screen
+func (o *PowerUps) Draw(screen *ebiten.Image, between float64) {
+	for _, v := range o.PowerUps {
+		at := v.Between(between)
+		x, y, r := float32(at.X), float32(at.Y), float32(v.Radius)
+		vector.DrawFilledCircle(screen, x, y, r, color.RGBA{0, 0, 0, 200}, true)
+		vector.StrokeCircle(screen, x, y, r, 3, PowerColours[v.Power], true)
+
+		letter := v.Power.String()[:1]
+		bounds, _ := font.BoundString(o.FontFace, letter)
+		p := at.Point()
+		text.Draw(screen, letter, o.FontFace,
+			p.X-(bounds.Max.X+bounds.Min.X).Ceil()/2,
+			p.Y-(bounds.Max.Y+bounds.Min.Y).Ceil()/2,
+			PowerColours[v.Power],
+		)
 	}
-	return false
 }
 
 // An Explosion is an animated impact explosion
 type Explosion struct {
 	*Object
-	Frame     int
-	Exploding bool
-	Done      bool
 }
 
-// Update sets positioning and animation for Explosions
-func (o *Explosion) Update(g *Game, coords image.Point) {
-	o.Center.X, o.Center.Y = coords.X, coords.Y
-	o.Op.GeoM.Reset()
-	o.Op.GeoM.Translate(float64(o.Center.X), float64(o.Center.Y))
-	o.Op.GeoM.Translate(-o.Radius, -o.Radius)
-
-	if o.Exploding {
-		if o.Frame < 7 {
-			o.Frame++
-		} else {
-			o.Frame = 1
-			o.Exploding = false
-			o.Done = true
-		}
-	}
-}
-
-// Draw renders an Explosion to the screen
-func (o *Explosion) Draw(screen *ebiten.Image) {
+// Draw renders the current frame of an exploding Explosion to the screen
+func (o *Explosion) Draw(screen *ebiten.Image, e *sim.Explosion, between float64) {
 	const frameSize int = 87
-	if o.Exploding {
+	if e.Exploding {
+		o.Place(e.Between(between), 0)
 		screen.DrawImage(o.Image.SubImage(image.Rect(
-			o.Frame*frameSize, 0, // top-left
-			(1+o.Frame)*frameSize, frameSize, // bottom-right
+			e.Frame*frameSize, 0, // top-left
+			(1+e.Frame)*frameSize, frameSize, // bottom-right
 		)).(*ebiten.Image), o.Op)
 	}
 }
 
+// PlayerColours are what colour each player's laser is, and their crosshair
+// in co-op
+var PlayerColours = []color.RGBA{
+	{255, 0, 0, 255},
+	{0, 200, 255, 255},
+}
+
 // The Crosshair is a target showing where the the player will shoot
 type Crosshair struct {
 	*Object
-	CoolingDown  bool
-	Shooting     bool
-	Missing      bool
-	ShootingFrom image.Point
-	Explosion    *Explosion
+	Explosion *Explosion
+	Crosshair *sim.Crosshair
+	Player    int  // whose crosshair it is, counting from zero
+	Hidden    bool // when the player is tapping where to shoot instead
 }
 
-// Update recalculates the crosshair position
+// Update takes the crosshair's state from the game world
 func (o *Crosshair) Update(g *Game) {
-	o.Shooting = false
-	o.Missing = false
+	o.Crosshair = g.World.Crosshairs[o.Player]
+	o.Hidden = o.Player == 0 && g.Controls.Touching
+	o.Place(o.Crosshair.Center, 0)
+	o.Op.ColorScale.Reset()
+	if o.Player > 0 {
+		o.Op.ColorScale.ScaleWithColor(PlayerColours[o.Player])
+	}
+}
 
-	o.Op.GeoM.Reset()
-	o.Center = image.Pt(ebiten.CursorPosition())
-	o.Op.GeoM.Translate(
-		float64(o.Center.X)-o.Radius,
-		float64(o.Center.Y)-o.Radius,
-	)
+// Layer is where Crosshairs are drawn, in front of everything else
+func (o *Crosshair) Layer() int { return CrosshairLayer }
 
-	canShoot := !g.Breathless && !o.CoolingDown && !g.GameOver && g.Wave > 0
-	if canShoot && clicked() {
-		o.Missing = true
-		o.Shooting = true
-		o.ShootingFrom = g.Moon.Center
-		g.Sounds.Laser.Rewind()
-		g.Sounds.Laser.Play()
-		for _, v := range g.Asteroids {
-			if o.Overlaps(v.Object) && v.Alive && !v.Explosion.Exploding {
-				v.Explosion.Exploding = true
-				soundEffectDelay := time.NewTimer(time.Millisecond * 100)
-				go func() {
-					<-soundEffectDelay.C
-					g.Sounds.ExplsnMid.Rewind()
-					g.Sounds.ExplsnMid.Play()
-				}()
-				g.Count--
-				o.Missing = false
-			}
-		}
+// Draw renders a Crosshair to the screen, where the player's aiming now
+func (o *Crosshair) Draw(screen *ebiten.Image, between float64) {
+	if o.Crosshair == nil {
+		return
 	}
 
-	if o.Missing {
-		o.CoolingDown = true
-		o.Explosion.Exploding = true
-		coolDownTimer := time.NewTimer(time.Second)
-		go func() {
-			<-coolDownTimer.C
-			o.CoolingDown = false
-		}()
+	if !o.Hidden {
+		screen.DrawImage(o.Image, o.Op)
 	}
-
-	o.Explosion.Update(g, g.Moon.Center)
-}
-
-// Draw renders a Crosshair to the screen
-func (o *Crosshair) Draw(screen *ebiten.Image) {
-	screen.DrawImage(o.Image, o.Op)
-	o.Explosion.Draw(screen)
-
-	// Draw laser from the moon to the crosshair
-	if o.Shooting {
-		ebitenutil.DrawLine(
-			screen,
-			float64(o.ShootingFrom.X),
-			float64(o.ShootingFrom.Y),
-			float64(o.Center.X),
-			float64(o.Center.Y),
-			color.RGBA{255, 0, 0, 255},
-		)
+	o.Explosion.Draw(screen, o.Crosshair.Explosion, between)
+
+	// Draw laser from the moon to wherever it stopped, and to anything
+	// multi-shot hit
+	if o.Crosshair.Shooting {
+		o.drawLaser(screen, o.Crosshair.ShootingTo)
+		for _, target := range o.Crosshair.Targets {
+			o.drawLaser(screen, target)
+		}
 	}
 }
 
-// Shorthand for when the left mouse button has just been clicked
-func clicked() bool {
-	return inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft)
+// drawLaser draws a line from the turret that fired to target
+func (o *Crosshair) drawLaser(screen *ebiten.Image, target sim.Vec) {
+	ebitenutil.DrawLine(
+		screen,
+		o.Crosshair.ShootingFrom.X,
+		o.Crosshair.ShootingFrom.Y,
+		target.X,
+		target.Y,
+		PlayerColours[o.Player],
+	)
 }
 
 // Load an image from embedded FS into an ebiten Image object
@@ -0,0 +1,145 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+package sim
+
+import (
+	"image/color"
+	"math"
+	"math/rand"
+)
+
+// An AsteroidType describes a kind of asteroid, how big, fast and tough it is
+type AsteroidType struct {
+	Name      string
+	Scale     float64    // size compared to a normal asteroid
+	Speed     float64    // how far it moves each tick
+	Path      Path       // the way it travels towards the Earth
+	HitPoints int        // how many laser hits it takes to destroy
+	Fragments int        // how many asteroids it splits into when shot
+	Fragment  string     // which type of asteroid it splits into
+	Weight    int        // how common it is in a wave, zero for never
+	FromWave  int        // first wave it can turn up in
+	Tint      color.RGBA // colour to draw it in
+}
+
+// AsteroidTypes are all the kinds of asteroids there are
+var AsteroidTypes = []*AsteroidType{
+	{Name: "Normal", Scale: 1, Speed: 1, HitPoints: 1, Weight: 6, FromWave: 1, Tint: color.RGBA{255, 255, 255, 255}},
+	{Name: "Small", Scale: 0.6, Speed: 1.8, HitPoints: 1, Weight: 3, FromWave: 2, Path: Curved, Tint: color.RGBA{255, 240, 200, 255}},
+	{Name: "Large", Scale: 1.8, Speed: 0.5, HitPoints: 2, Weight: 2, FromWave: 3, Tint: color.RGBA{220, 200, 180, 255}},
+	{Name: "Splitting", Scale: 1.4, Speed: 0.8, HitPoints: 1, Fragments: 3, Fragment: "Fragment", Weight: 2, FromWave: 3, Path: Curved, Tint: color.RGBA{255, 160, 130, 255}},
+	{Name: "Armoured", Scale: 1.2, Speed: 0.8, HitPoints: 3, Weight: 1, FromWave: 4, Path: Spiral, Tint: color.RGBA{150, 170, 255, 255}},
+	{Name: "Fragment", Scale: 0.5, Speed: 1.5, HitPoints: 1, Weight: 0, FromWave: 1, Tint: color.RGBA{255, 160, 130, 255}},
+}
+
+// FragmentSpread is the angle between fragments of a split asteroid
+var FragmentSpread float64 = 0.08
+
+// AsteroidTypeByName finds an AsteroidType, or returns nil if there isn't one
+// with that name
+func AsteroidTypeByName(name string) *AsteroidType {
+	for _, t := range AsteroidTypes {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// pickAsteroidType chooses a type of asteroid for the given wave at random,
+// weighted by how common each type is
+func pickAsteroidType(rng *rand.Rand, wave int) *AsteroidType {
+	candidate := func(t *AsteroidType) bool { return t.Weight > 0 && t.FromWave <= wave }
+	var last *AsteroidType
+	candidates, total := 0, 0
+	for _, t := range AsteroidTypes {
+		if candidate(t) {
+			last = t
+			candidates++
+			total += t.Weight
+		}
+	}
+	if candidates == 0 {
+		return AsteroidTypes[0]
+	}
+	if candidates == 1 {
+		return last
+	}
+
+	n := rng.Intn(total)
+	for _, t := range AsteroidTypes {
+		if !candidate(t) {
+			continue
+		}
+		if n < t.Weight {
+			return t
+		}
+		n -= t.Weight
+	}
+	return last
+}
+
+// NewAsteroid makes a single living asteroid of the given type
+func NewAsteroid(t *AsteroidType, sizes Sizes, angle, distance float64) *Asteroid {
+	o := &Asteroid{
+		Object:    &Object{},
+		Explosion: &Explosion{Object: &Object{}},
+	}
+	o.init(t, sizes, angle, distance)
+	return o
+}
+
+// init makes o good as new, a living asteroid of the given type, keeping
+// its Object and Explosion so that reusing it doesn't allocate
+func (o *Asteroid) init(t *AsteroidType, sizes Sizes, angle, distance float64) {
+	*o.Object = Object{Radius: sizes.Asteroid * t.Scale}
+	*o.Explosion.Object = Object{Radius: sizes.Explosion}
+	*o.Explosion = Explosion{Object: o.Explosion.Object, Frame: 1}
+	*o = Asteroid{
+		Object:    o.Object,
+		Type:      t,
+		HitPoints: t.HitPoints,
+		Speed:     t.Speed,
+		Turn:      1,
+		Explosion: o.Explosion,
+		Alive:     true,
+		Impacting: false,
+	}
+	o.Place(angle, distance, sizes.Earth)
+}
+
+// Split breaks a shot asteroid into its fragments, fanned out around where it
+// was, and adds them to fragments, which stays the same if the asteroid
+// doesn't split
+func (o *Asteroid) Split(p *Pool, sizes Sizes, fragments Asteroids) Asteroids {
+	fragment := AsteroidTypeByName(o.Type.Fragment)
+	if o.Type.Fragments <= 0 || fragment == nil {
+		return fragments
+	}
+	middle := float64(o.Type.Fragments-1) / 2
+	for i := 0; i < o.Type.Fragments; i++ {
+		angle := o.Angle + (float64(i)-middle)*FragmentSpread
+		fragments = append(fragments, p.Asteroid(fragment, sizes, math.Mod(angle, math.Pi*2), o.Distance))
+	}
+	return fragments
+}
+
+// AddAsteroids puts more asteroids into the current wave, where they were
+// placed
+func (w *World) AddAsteroids(as Asteroids) {
+	for _, v := range as {
+		v.jump(w)
+	}
+	w.Asteroids = append(w.Asteroids, as...)
+	w.Grid.Stale = true
+	w.Count += len(as)
+}
+
+// addFragments adds the asteroids split off by the last shot
+func (w *World) addFragments() {
+	w.AddAsteroids(w.fragments)
+	clear(w.fragments)
+	w.fragments = w.fragments[:0]
+}
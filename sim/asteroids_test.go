@@ -0,0 +1,83 @@
+package sim
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestWaveMix(t *testing.T) {
+	for _, v := range NewAsteroids(rand.New(rand.NewSource(1)), DefaultSizes, 50, 1) {
+		if v.Type.Name != "Normal" {
+			t.Errorf("%s asteroid in the first wave", v.Type.Name)
+		}
+	}
+
+	seen := map[string]int{}
+	for _, v := range NewAsteroids(rand.New(rand.NewSource(1)), DefaultSizes, 500, 10) {
+		seen[v.Type.Name]++
+	}
+	for _, kind := range AsteroidTypes {
+		if kind.Weight > 0 && seen[kind.Name] == 0 {
+			t.Errorf("no %s asteroids in a big late wave", kind.Name)
+		}
+		if kind.Weight == 0 && seen[kind.Name] > 0 {
+			t.Errorf("%d %s asteroids in a wave", seen[kind.Name], kind.Name)
+		}
+	}
+}
+
+// shoot fires the laser at target, waiting for the crosshair to cool down
+func shoot(w *World, target *Asteroid) {
+	for w.Crosshair.CoolingDown {
+		w.Update(Input{Cursor: target.Center.Point()})
+	}
+	w.Update(Input{Cursor: target.Center.Point(), Clicked: true})
+}
+
+func TestArmouredAsteroid(t *testing.T) {
+	w := newTestWorld(t)
+	target := NewAsteroid(AsteroidTypeByName("Armoured"), w.Sizes, 0, 300)
+	w.Asteroids = Asteroids{target}
+	w.Count = 1
+	w.Update(Input{})
+
+	for i := 1; i < target.Type.HitPoints; i++ {
+		shoot(w, target)
+		if !hasEvent(w, AsteroidDamaged) || target.Explosion.Exploding {
+			t.Fatalf("hit %d destroyed an armoured asteroid", i)
+		}
+	}
+	shoot(w, target)
+	if !target.Explosion.Exploding || w.Count != 0 {
+		t.Errorf("armoured asteroid survived %d hits", target.Type.HitPoints)
+	}
+}
+
+func TestSplittingAsteroid(t *testing.T) {
+	w := newTestWorld(t)
+	target := NewAsteroid(AsteroidTypeByName("Splitting"), w.Sizes, 0, 300)
+	w.Asteroids = Asteroids{target}
+	w.Count = 1
+	w.Update(Input{})
+
+	shoot(w, target)
+	if len(w.Asteroids) != 1+target.Type.Fragments || w.Count != target.Type.Fragments {
+		t.Fatalf("split into %d asteroids, count %d", len(w.Asteroids)-1, w.Count)
+	}
+	for _, v := range w.Asteroids[1:] {
+		if v.Type.Name != target.Type.Fragment || v.Radius >= target.Radius {
+			t.Errorf("fragment is a %s of radius %v", v.Type.Name, v.Radius)
+		}
+	}
+}
+
+func TestAsteroidSpeed(t *testing.T) {
+	w := newTestWorld(t)
+	small := NewAsteroid(AsteroidTypeByName("Small"), w.Sizes, 0, 300)
+	large := NewAsteroid(AsteroidTypeByName("Large"), w.Sizes, 0, 300)
+	small.Update(w)
+	large.Update(w)
+	if small.Distance >= large.Distance {
+		t.Errorf("small asteroid at %v isn't ahead of large one at %v", small.Distance, large.Distance)
+	}
+}
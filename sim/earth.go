@@ -0,0 +1,49 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+package sim
+
+import "time"
+
+var (
+	EarthHealth      float64 = 100 // how much damage the Earth can take
+	EarthShield      float64 = 40  // how much damage the shield can soak up, 0 for no shield
+	ShieldRegen      float64 = 5   // how much shield comes back each second
+	ShieldRegenDelay float64 = 3   // how many seconds after a hit the shield starts coming back
+	ImpactDamage     float64 = 20  // how much damage a normal sized asteroid does, bigger ones do more
+)
+
+// Reset heals the Earth and brings its shield back up for a new game
+func (o *Earth) Reset() {
+	o.Impacted = false
+	o.Health = EarthHealth
+	o.Shield = EarthShield
+	o.ShieldDelay = 0
+}
+
+// Damage hurts the Earth, taking what it can out of the shield first, and
+// reports whether that was the end of it
+func (o *Earth) Damage(w *World, damage float64) bool {
+	o.ShieldDelay = Ticks(time.Duration(ShieldRegenDelay * float64(time.Second)))
+	if o.Shield > 0 {
+		w.Emit(ShieldHit)
+		absorbed := damage
+		if absorbed > o.Shield {
+			absorbed = o.Shield
+		}
+		o.Shield -= absorbed
+		damage -= absorbed
+	}
+	if damage <= 0 {
+		return false
+	}
+
+	w.Emit(EarthHit)
+	o.Health -= damage
+	if o.Health <= 0 {
+		o.Health = 0
+		return true
+	}
+	return false
+}
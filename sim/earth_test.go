@@ -0,0 +1,77 @@
+package sim
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEarthDamage(t *testing.T) {
+	w := newTestWorld(t)
+	e := w.Earth
+	e.Shield = 10
+
+	if e.Damage(w, 25) {
+		t.Fatalf("25 damage destroyed the Earth")
+	}
+	if e.Shield != 0 || e.Health != EarthHealth-15 {
+		t.Errorf("shield %v, health %v after 25 damage, want 0, %v", e.Shield, e.Health, EarthHealth-15)
+	}
+	if !hasEvent(w, ShieldHit) || !hasEvent(w, EarthHit) {
+		t.Errorf("events = %v, want shield and Earth hit", w.Events)
+	}
+	if !e.Damage(w, EarthHealth) || e.Health != 0 {
+		t.Errorf("Earth survived with %v health", e.Health)
+	}
+}
+
+func TestImpactScaledBySize(t *testing.T) {
+	w := newTestWorld(t)
+	w.Earth.Shield = 0
+	for _, name := range []string{"Small", "Large"} {
+		a := NewAsteroid(AsteroidTypeByName(name), w.Sizes, 0, 0)
+		w.AddAsteroids(Asteroids{a})
+	}
+	w.Asteroids[0].Alive = false // only the new ones
+
+	health := w.Earth.Health
+	count := w.Count
+	w.Update(Input{})
+	want := health - ImpactDamage*(AsteroidTypeByName("Small").Scale+AsteroidTypeByName("Large").Scale)
+	if w.Earth.Health != want {
+		t.Errorf("health %v after a small and a large asteroid, want %v", w.Earth.Health, want)
+	}
+	if w.Earth.Impacted || w.Count != count-2 {
+		t.Errorf("impacted %v, count %d after surviving hits, want %d", w.Earth.Impacted, w.Count, count-2)
+	}
+}
+
+func TestShieldRegenerates(t *testing.T) {
+	w := newTestWorld(t)
+	w.Earth.Damage(w, EarthShield)
+	if w.Earth.Shield != 0 {
+		t.Fatalf("shield %v after taking all it can", w.Earth.Shield)
+	}
+
+	delay := Ticks(time.Duration(ShieldRegenDelay * float64(time.Second)))
+	for i := 0; i < delay; i++ {
+		w.Earth.Update(w)
+	}
+	if w.Earth.Shield != 0 {
+		t.Errorf("shield came back %v before the delay", w.Earth.Shield)
+	}
+	for i := 0; i < TPS*60; i++ {
+		w.Earth.Update(w)
+	}
+	if w.Earth.Shield != EarthShield {
+		t.Errorf("shield %v after a minute, want %v", w.Earth.Shield, EarthShield)
+	}
+}
+
+func TestEarthHealedForNewGame(t *testing.T) {
+	w := newTestWorld(t)
+	w.Earth.Health, w.Earth.Shield = 1, 0
+	w.StartOver()
+	if w.Earth.Health != EarthHealth || w.Earth.Shield != EarthShield {
+		t.Errorf("health %v, shield %v in a new game", w.Earth.Health, w.Earth.Shield)
+	}
+}
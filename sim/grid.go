@@ -0,0 +1,152 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+package sim
+
+import (
+	"image"
+	"math"
+	"slices"
+)
+
+var GridCell float64 = 64 // how wide each square of the collision grid is
+
+// A Grid sorts asteroids into squares by where they are, so collision checks
+// only need to look at the asteroids near what they're checking instead of
+// every asteroid there is. Each asteroid goes in every square its bounding
+// box touches, so two asteroids that overlap always share a square, anything
+// off the screen goes in the squares round the edge
+type Grid struct {
+	Cell   float64
+	Stale  bool // whether anything moved since the Grid was built
+	cols   int
+	rows   int
+	starts []int     // where each square's asteroids start in sorted, and one more for the end
+	ends   []int     // where each square's asteroids end in sorted, while it's being built
+	sorted Asteroids // every square's asteroids, one square after another
+	mark   int       // stamps asteroids already found by a query
+}
+
+// NewGrid makes an empty Grid of squares cell pixels wide covering a screen
+// of the given size
+func NewGrid(cell float64, width, height int) *Grid {
+	g := &Grid{
+		Cell:  cell,
+		Stale: true,
+		cols:  int(math.Ceil(float64(width)/cell)) + 1,
+		rows:  int(math.Ceil(float64(height)/cell)) + 1,
+	}
+	g.starts = make([]int, g.cols*g.rows+1)
+	g.ends = make([]int, g.cols*g.rows)
+	return g
+}
+
+// Build sorts all the asteroids in play into the Grid, it counts how many
+// go in each square first so they can all share one slice, which is kept
+// between builds so that rebuilding every tick doesn't allocate
+func (g *Grid) Build(as Asteroids) {
+	clear(g.starts)
+	g.each(as, func(v *Asteroid, square int) {
+		g.starts[square+1]++
+	})
+	for i := 1; i < len(g.starts); i++ {
+		g.starts[i] += g.starts[i-1]
+	}
+
+	total := g.starts[len(g.starts)-1]
+	g.sorted = slices.Grow(g.sorted[:0], total)[:total]
+	copy(g.ends, g.starts)
+	g.each(as, func(v *Asteroid, square int) {
+		g.sorted[g.ends[square]] = v
+		g.ends[square]++
+	})
+	g.Stale = false
+}
+
+// each calls fn for every square each asteroid in play touches
+func (g *Grid) each(as Asteroids, fn func(v *Asteroid, square int)) {
+	for _, v := range as {
+		if !v.Alive || v.Waiting {
+			continue
+		}
+		min, max := g.bounds(v.Center, v.Radius)
+		for y := min.Y; y <= max.Y; y++ {
+			for x := min.X; x <= max.X; x++ {
+				fn(v, y*g.cols+x)
+			}
+		}
+	}
+}
+
+// bounds are the first and last squares touched by a circle at p
+func (g *Grid) bounds(p Vec, r float64) (min, max image.Point) {
+	return g.square(p.X-r, p.Y-r), g.square(p.X+r, p.Y+r)
+}
+
+// square is which square x, y is in, or the nearest one on the edge
+func (g *Grid) square(x, y float64) image.Point {
+	col := int(math.Max(0, math.Min(float64(g.cols-1), math.Floor(x/g.Cell))))
+	row := int(math.Max(0, math.Min(float64(g.rows-1), math.Floor(y/g.Cell))))
+	return image.Pt(col, row)
+}
+
+// appendNear adds every asteroid in the squares touched by a circle at p to
+// buf, skipping any already added since the mark last changed
+func (g *Grid) appendNear(buf Asteroids, p Vec, r float64) Asteroids {
+	min, max := g.bounds(p, r)
+	for y := min.Y; y <= max.Y; y++ {
+		for x := min.X; x <= max.X; x++ {
+			square := y*g.cols + x
+			for _, v := range g.sorted[g.starts[square]:g.starts[square+1]] {
+				if v.mark != g.mark {
+					v.mark = g.mark
+					buf = append(buf, v)
+				}
+			}
+		}
+	}
+	return buf
+}
+
+// grid is the collision Grid, rebuilt first if anything moved
+func (w *World) grid() *Grid {
+	if w.Grid.Stale {
+		w.Grid.Build(w.Asteroids)
+	}
+	w.Grid.mark++
+	return w.Grid
+}
+
+// Near is every asteroid in play that might overlap a circle at p, some may
+// not really, it's only good until Near is used again
+func (w *World) Near(p Vec, r float64) Asteroids {
+	w.near = w.grid().appendNear(w.near[:0], p, r)
+	return w.near
+}
+
+// Along is every hittable asteroid the line from a to b, widened by width on
+// either side, passes through, nearest to a first, it's the same as
+// Asteroids.Along but only checks the asteroids near the line
+func (w *World) Along(a, b Vec, width float64) Asteroids {
+	g := w.grid()
+
+	// Look around points along the line close enough together that every
+	// square within width of the line gets looked in
+	d := b.Sub(a)
+	steps := int(d.Len()/(g.Cell/2)) + 1
+	w.near = w.near[:0]
+	for i := 0; i <= steps; i++ {
+		p := a.Add(d.Mul(float64(i) / float64(steps)))
+		w.near = g.appendNear(w.near, p, width+g.Cell/2)
+	}
+	w.near.along(&w.along, a, b, width)
+	return w.along.as
+}
+
+// Nearest is the hittable asteroid closest to p within the given distance,
+// and not one of the exceptions, or nil if there isn't one, it's the same as
+// Asteroids.Nearest but only checks the asteroids that are close enough
+func (w *World) Nearest(p Vec, within float64, except Asteroids) *Asteroid {
+	return w.Near(p, within).Nearest(p, within, except)
+}
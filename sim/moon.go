@@ -0,0 +1,145 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+package sim
+
+import (
+	"log"
+	"math"
+)
+
+var (
+	ExtraMoonWaves    int     = 5      // a new moon joins every this many waves, 0 for never
+	MaxMoons          int     = 2      // most moons there can be at once
+	MoonSpacing       float64 = 2      // how many half-moons closer in each new moon orbits
+	MoonSteering      bool    = false  // whether the player controls the Moon's orbit
+	OrbitAcceleration float64 = 0.0004 // how much each tick of thrust changes the Moon's orbit speed
+	MaxOrbitRatio     float64 = 3      // how many times faster than usual the Moon can orbit, either way
+	MoonEnergy        float64 = 100    // how much thrust the Moon has in reserve when fully charged
+	ThrustCost        float64 = 1      // how much energy each tick of thrust uses
+	EnergyRegen       float64 = 15     // how much energy comes back each second without thrust
+)
+
+// NewMoon makes a moon with its own turret, orbiting distance half-moons away
+// from the Earth and starting phase of the way round
+func NewMoon(sizes Sizes, distance, phase float64) *Moon {
+	o := &Moon{
+		Object: NewObject(sizes.Moon),
+		Turret: &Turret{
+			Object: NewObject(sizes.Turret),
+			Angle:  0,
+		},
+		Phase:    phase,
+		Distance: distance,
+	}
+	o.Reset()
+	return o
+}
+
+// Moons are all the moons going round the Earth, the first one is the Moon
+// the game starts with
+type Moons []*Moon
+
+// Settle marks where all the moons are at the start of a tick
+func (ms Moons) Settle() {
+	for _, m := range ms {
+		m.Settle()
+	}
+}
+
+// Update moves all the moons along their orbits
+func (ms Moons) Update(w *World) {
+	for _, m := range ms {
+		m.Update(w)
+	}
+}
+
+// Nearest is the moon closest to p
+func (ms Moons) Nearest(p Vec) *Moon {
+	var nearest *Moon
+	best := math.Inf(1)
+	for _, m := range ms {
+		if d := m.Center.Sub(p).Len(); d < best {
+			nearest, best = m, d
+		}
+	}
+	return nearest
+}
+
+// closestMoon is the nearest a moon can orbit, in half-moons from the
+// Earth's surface, any closer and it would scrape the Earth
+const closestMoon = 2
+
+// AddMoon puts another moon in orbit closer in than the last one, on the
+// other side of the Earth from it, with the same upgrades as the Moon, or
+// returns nil if there's no room for its orbit outside the Earth
+func (w *World) AddMoon() *Moon {
+	last := w.Moons[len(w.Moons)-1]
+	distance := last.Distance - MoonSpacing
+	if distance < closestMoon {
+		return nil
+	}
+	m := NewMoon(w.Sizes, distance, last.Phase+math.Pi)
+	m.Shots = w.Moon.Shots
+	m.Piercing = w.Moon.Piercing
+	m.OrbitLevel = w.Moon.OrbitLevel
+	m.jump(w)
+	w.Moons = append(w.Moons, m)
+	log.Printf("moon %d joins on wave %d\n", len(w.Moons), w.Wave)
+	return m
+}
+
+// unlockMoons adds a moon every ExtraMoonWaves waves, up to MaxMoons or as
+// many as fit round the Earth
+func (w *World) unlockMoons() {
+	for ExtraMoonWaves > 0 && len(w.Moons) < MaxMoons && w.Wave >= len(w.Moons)*ExtraMoonWaves {
+		if w.AddMoon() == nil {
+			return
+		}
+	}
+}
+
+// ResetMoons goes back to just the Moon, at its usual orbit speed
+func (w *World) ResetMoons() {
+	w.Moons = w.Moons[:1]
+	w.Moon.Reset()
+	for _, c := range w.Crosshairs {
+		c.Turret = 0
+		c.Firing = w.Moon
+	}
+}
+
+// Reset puts the Moon back to its usual orbit speed with full energy
+func (o *Moon) Reset() {
+	o.OrbitSpeed = RotationSpeed / MoonOrbitRatio
+	o.Energy = MoonEnergy
+}
+
+// steer speeds the Moon up or slows it down when the player's thrusting
+// and has the energy for it, it keeps its momentum otherwise, positive
+// speeds go round the usual way and negative ones go backwards
+func (o *Moon) steer(w *World) {
+	upgrade := 1 + OrbitUpgrade*float64(o.OrbitLevel)
+	max := RotationSpeed / MoonOrbitRatio * MaxOrbitRatio * upgrade
+
+	thrust := w.Input.Thrust
+	if w.State != Playing || o.Energy < ThrustCost {
+		thrust = 0
+	}
+	if thrust == 0 {
+		o.Energy += EnergyRegen / TPS
+		if o.Energy > MoonEnergy {
+			o.Energy = MoonEnergy
+		}
+		return
+	}
+
+	o.Energy -= ThrustCost
+	o.OrbitSpeed += float64(thrust) * OrbitAcceleration * upgrade
+	if o.OrbitSpeed > max {
+		o.OrbitSpeed = max
+	} else if o.OrbitSpeed < -max {
+		o.OrbitSpeed = -max
+	}
+}
@@ -0,0 +1,142 @@
+package sim
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSteering(t *testing.T) {
+	defer func(s bool) { MoonSteering = s }(MoonSteering)
+	MoonSteering = true
+
+	w := newTestWorld(t)
+	usual := RotationSpeed / MoonOrbitRatio
+	if w.Moon.OrbitSpeed != usual || w.Moon.Energy != MoonEnergy {
+		t.Fatalf("Moon starts at speed %v with %v energy", w.Moon.OrbitSpeed, w.Moon.Energy)
+	}
+
+	// Coasting keeps the same speed
+	phase := w.Moon.Phase
+	w.Update(Input{})
+	if got := phase - w.Moon.Phase; math.Abs(got-usual) > 1e-9 {
+		t.Errorf("coasting Moon moved %v, want %v", got, usual)
+	}
+
+	w.Update(Input{Thrust: 1})
+	if w.Moon.OrbitSpeed <= usual || w.Moon.Energy >= MoonEnergy {
+		t.Errorf("after thrust speed %v, energy %v", w.Moon.OrbitSpeed, w.Moon.Energy)
+	}
+
+	// Braking for long enough reverses the orbit, up to the limit
+	for i := 0; i < TPS*10; i++ {
+		w.Moon.Energy = MoonEnergy
+		w.Update(Input{Thrust: -1})
+	}
+	if max := usual * MaxOrbitRatio; w.Moon.OrbitSpeed != -max {
+		t.Errorf("braked to %v, want %v", w.Moon.OrbitSpeed, -max)
+	}
+	phase = w.Moon.Phase
+	w.Update(Input{})
+	if w.Moon.Phase <= phase {
+		t.Errorf("reversed Moon went from phase %v to %v", phase, w.Moon.Phase)
+	}
+}
+
+func TestSteeringEnergy(t *testing.T) {
+	defer func(s bool) { MoonSteering = s }(MoonSteering)
+	MoonSteering = true
+
+	w := newTestWorld(t)
+	ticks := int(MoonEnergy / ThrustCost)
+	for i := 0; i < ticks; i++ {
+		w.Update(Input{Thrust: 1})
+	}
+	speed := w.Moon.OrbitSpeed
+	w.Update(Input{Thrust: 1})
+	if w.Moon.OrbitSpeed != speed {
+		t.Errorf("Moon sped up with %v energy", w.Moon.Energy)
+	}
+
+	// Letting go recharges the energy
+	for i := 0; i < TPS; i++ {
+		w.Update(Input{})
+	}
+	if w.Moon.Energy < EnergyRegen {
+		t.Errorf("energy %v after a second's rest, want at least %v", w.Moon.Energy, EnergyRegen)
+	}
+	if w.Moon.OrbitSpeed != speed {
+		t.Errorf("Moon slowed from %v to %v while coasting", speed, w.Moon.OrbitSpeed)
+	}
+}
+
+func TestMoonUnlock(t *testing.T) {
+	w := newTestWorld(t)
+	w.Moon.Shots = 1
+	w.Wave = ExtraMoonWaves - 1
+	w.Restart()
+	if len(w.Moons) != 1 {
+		t.Fatalf("%d moons on wave %d", len(w.Moons), w.Wave)
+	}
+
+	w.Wave = ExtraMoonWaves
+	w.Restart()
+	if len(w.Moons) != 2 {
+		t.Fatalf("%d moons on wave %d, want 2", len(w.Moons), w.Wave)
+	}
+	m := w.Moons[1]
+	if m.Distance != w.Moon.Distance-MoonSpacing || m.Phase != w.Moon.Phase+math.Pi {
+		t.Errorf("new moon %v half-moons out at phase %v", m.Distance, m.Phase)
+	}
+	if m.Shots != 1 {
+		t.Errorf("new moon has %d shots, want the Moon's upgrades", m.Shots)
+	}
+
+	w.StartOver()
+	if len(w.Moons) != 1 || w.Moons[0] != w.Moon {
+		t.Errorf("%d moons after starting over", len(w.Moons))
+	}
+}
+
+func TestMoonsClearOfEarth(t *testing.T) {
+	defer func(n int) { MaxMoons = n }(MaxMoons)
+	MaxMoons = 4
+	w := newTestWorld(t)
+	w.Wave = ExtraMoonWaves * MaxMoons
+	w.Restart()
+	if len(w.Moons) != 2 {
+		t.Errorf("%d moons, want only the ones that fit outside the Earth", len(w.Moons))
+	}
+	for _, m := range w.Moons {
+		if gap := m.orbiting(w).Len() - m.Radius - w.Earth.Radius; gap <= 0 {
+			t.Errorf("moon %v half-moons out is %v inside the Earth", m.Distance, -gap)
+		}
+	}
+}
+
+func TestTurretChoice(t *testing.T) {
+	w := newTestWorld(t)
+	w.Asteroids[0].Alive = false
+	w.AddMoon()
+	w.Update(Input{})
+	far, near := w.Moons[0], w.Moons[1]
+
+	if got := w.Crosshair.Moon(w); got != w.Moons.Nearest(w.Crosshair.Center) {
+		t.Errorf("firing from moon at %v, want the nearest", got.Center)
+	}
+
+	w.Update(Input{Cursor: near.Center.Point(), Clicked: true})
+	if w.Crosshair.ShootingFrom != near.Turret.Center || w.Crosshair.Firing != near {
+		t.Errorf("shot from %v, want the nearest turret at %v", w.Crosshair.ShootingFrom, near.Turret.Center)
+	}
+
+	// Picking a turret fires from it wherever the crosshair is, picking it
+	// again goes back to the nearest
+	w.Update(Input{Cursor: near.Center.Point(), Turret: 1})
+	if w.Crosshair.Turret != 1 || w.Crosshair.Moon(w) != far {
+		t.Errorf("picked turret %d, firing from %v", w.Crosshair.Turret, w.Crosshair.Moon(w).Center)
+	}
+	w.Update(Input{Cursor: near.Center.Point(), Turret: 1})
+	if w.Crosshair.Turret != 0 || w.Crosshair.Moon(w) != near {
+		t.Errorf("picked turret %d after picking it again", w.Crosshair.Turret)
+	}
+}
@@ -0,0 +1,583 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+package sim
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/sinisterstuf/lunar-defence/entity"
+)
+
+// An Object is something that has a position and size in the game world
+type Object struct {
+	Center Vec
+	Last   Vec // where it was when the tick started, to draw it between ticks
+	Radius float64
+}
+
+// Overlaps reports whether o and p have a non-empty intersection
+func (o *Object) Overlaps(p *Object) bool {
+	return o.Center.Sub(p.Center).Len() <= o.Radius+p.Radius
+}
+
+// Crosses reports whether the line from a to b, widened by width on either
+// side, passes through o, and how far along the line it gets closest to o
+func (o *Object) Crosses(a, b Vec, width float64) (bool, float64) {
+	d, f := b.Sub(a), o.Center.Sub(a)
+
+	// Find the point on the line closest to o, keeping it between a and b
+	t := 0.0
+	if length := d.Dot(d); length > 0 {
+		t = math.Max(0, math.Min(1, f.Dot(d)/length))
+	}
+	along := t * d.Len()
+	return d.Mul(t).Sub(f).Len() <= o.Radius+width, along
+}
+
+// Settle marks where o is at the start of a tick, so it's drawn moving
+// smoothly from here to wherever the tick takes it, it's also how to move
+// something straight to where it is without it being drawn on the way
+func (o *Object) Settle() {
+	o.Last = o.Center
+}
+
+// Between is where o is drawn a fraction t of the way through a tick, from
+// where it was when the tick started to where it is now
+func (o *Object) Between(t float64) Vec {
+	return o.Last.Lerp(o.Center, t)
+}
+
+// NewObject makes a new Object of the given radius at the origin
+func NewObject(radius float64) *Object {
+	return &Object{
+		Radius: radius,
+	}
+}
+
+// Moon is our moon, orbiting around the earth
+type Moon struct {
+	*Object
+	*Turret
+	Phase      float64 // how far round its orbit it is
+	Distance   float64 // how many half-moons away from the Earth it orbits
+	Velocity   Vec     // how far it moved last tick
+	OrbitSpeed float64 // how fast it goes round when the player steers it
+	Energy     float64 // how much thrust the player has left for steering
+}
+
+// Update recalculates moon position
+func (o *Moon) Update(w *World) {
+	last := o.orbiting(w)
+	if MoonSteering {
+		o.steer(w)
+		o.Phase -= o.OrbitSpeed
+	} else {
+		o.Phase -= RotationSpeed / MoonOrbitRatio * (1 + OrbitUpgrade*float64(o.OrbitLevel))
+	}
+	o.locate(w)
+	o.Velocity = o.orbiting(w).Sub(last)
+
+	entity.Each(w.Entities, func(_ entity.ID, c Collidable) {
+		c.Collide(w, o)
+	})
+
+	o.Turret.Update(w)
+}
+
+// orbiting is where the Moon is relative to the middle of the Earth
+func (o *Moon) orbiting(w *World) Vec {
+	return Polar(w.Earth.Radius+o.Radius*o.Distance, o.Orbit())
+}
+
+// locate calculates the Moon's centre, and its turret's, for collision
+// detection
+func (o *Moon) locate(w *World) {
+	o.Center = w.Earth.Center.Add(o.orbiting(w))
+	o.Turret.Center = o.Center
+}
+
+// Settle marks where the Moon and its turret are at the start of a tick
+func (o *Moon) Settle() {
+	o.Object.Settle()
+	o.Turret.Settle()
+}
+
+// jump puts the Moon where its orbit says straight away, without it being
+// drawn on the way there
+func (o *Moon) jump(w *World) {
+	o.locate(w)
+	o.Settle()
+}
+
+// Orbit is the angle of the Moon around the Earth, which is also how far the
+// Moon itself has spun
+func (o *Moon) Orbit() float64 {
+	return o.Phase
+}
+
+// A Turret is a weapon on the moon that shoots lasers
+type Turret struct {
+	*Object
+	Angle      float64
+	Shots      int  // how many more asteroids each shot hits, near the crosshair
+	Piercing   bool // whether the laser goes straight through armour
+	OrbitLevel int  // how much faster the Moon orbits
+}
+
+// Update calculates Turret game logic
+func (o *Turret) Update(w *World) {
+	// Follow the first crosshair this turret fires for, or the first player's
+	crosshair := w.Crosshair
+	for _, c := range w.Crosshairs {
+		if c.Moon(w).Turret == o {
+			crosshair = c
+			break
+		}
+	}
+
+	// Calculate rotation towards crosshair if it's not cooling down
+	if !crosshair.CoolingDown {
+		o.Aim(crosshair.Center)
+	}
+}
+
+// Aim points the Turret at p
+func (o *Turret) Aim(p Vec) {
+	o.Angle = o.Center.Sub(p).Angle()
+}
+
+// Earth is the earth, our home planet
+type Earth struct {
+	*Object
+	Impacted    bool
+	Health      float64
+	Shield      float64
+	ShieldDelay int // ticks until the shield starts to regenerate
+}
+
+// Update regenerates the Earth's shield a while after it was last hit, the
+// Earth itself stays where it is, its spin is only for show
+func (o *Earth) Update(w *World) {
+	if w.State != Playing {
+		return
+	}
+	if o.ShieldDelay > 0 {
+		o.ShieldDelay--
+		return
+	}
+	o.Shield = math.Min(o.Shield+ShieldRegen/TPS, EarthShield)
+}
+
+// Asteroid is an asteroid on impact course with the Earth
+type Asteroid struct {
+	*Object
+	Type      *AsteroidType
+	HitPoints int
+	Speed     float64
+	Angle     float64 // where it is around the Earth
+	Distance  float64 // how far it is from the Earth's surface
+	Position  Vec     // where it is relative to the middle of the Earth
+	Velocity  Vec     // how far it moves each tick
+	Turn      float64 // which way it curves, 1 or -1
+	Careering int     // ticks left that it crashes into other asteroids after being knocked
+	Explosion *Explosion
+	Alive     bool
+	Impacting bool
+	Waiting   bool // to be released by the Spawner
+	mark      int  // the last Grid query that found it
+}
+
+// Update recalculates Asteroid position
+func (o *Asteroid) Update(w *World) {
+	// Waiting asteroids told to explode were never seen, so they just vanish
+	if o.Waiting {
+		if o.Explosion.Exploding {
+			o.Alive = false
+		}
+		return
+	}
+
+	if o.Careering > 0 {
+		o.Careering--
+	}
+
+	if !o.Impacting {
+		dt := 1.0
+		if w.Effects.Active(SlowMotion) {
+			dt = SlowMotionRatio
+		}
+		o.move(w.Earth.Object, w.Moons, dt)
+	}
+
+	o.locate(w)
+
+	// Asteroid impacts earth, unless the shield stops it, it's only fatal if
+	// it takes the last of the Earth's health
+	if o.Hittable() && o.Overlaps(w.Earth.Object) {
+		o.Explosion.Exploding = true
+		if w.Effects.Active(Shield) {
+			w.Emit(ShieldHit)
+			w.Count--
+		} else if w.Earth.Damage(w, ImpactDamage*o.Type.Scale) {
+			o.Impacting = true
+		} else {
+			w.Count--
+		}
+	}
+
+	// Handle Explosion
+	o.Explosion.Update(o.Object)
+	if o.Explosion.Done && o.Alive {
+		o.Alive = false
+	}
+}
+
+// locate calculates an asteroid's centre on screen for collision detection
+func (o *Asteroid) locate(w *World) {
+	w.Grid.Stale = true
+	o.Center = w.Earth.Center.Add(o.Position)
+}
+
+// jump puts an asteroid where it's been placed straight away, without it
+// being drawn on the way there
+func (o *Asteroid) jump(w *World) {
+	o.locate(w)
+	o.Settle()
+}
+
+// Ram destroys an asteroid by crashing into it
+func (w *World) Ram(v *Asteroid) {
+	v.Explosion.Exploding = true
+	w.Emit(AsteroidRammed)
+	w.AddScore(PointsRammed)
+	w.Count--
+	w.Credits += CreditsPerAsteroid
+	w.Drop(v)
+}
+
+// Hittable reports whether an asteroid can be shot or rammed
+func (o *Asteroid) Hittable() bool {
+	return o.Alive && !o.Waiting && !o.Explosion.Exploding
+}
+
+// Asteroids are multiple of a single Asteroid
+type Asteroids []*Asteroid
+
+// Collide rams or knocks back any Asteroids the Moon runs into
+func (as Asteroids) Collide(w *World, m *Moon) {
+	for _, v := range w.Near(m.Center, m.Radius) {
+		if v.Hittable() && m.Overlaps(v.Object) {
+			if MoonKnockback {
+				w.Knock(m, v)
+			} else {
+				w.Ram(v)
+			}
+		}
+	}
+}
+
+// Settle marks where all the Asteroids are at the start of a tick
+func (as Asteroids) Settle() {
+	for _, v := range as {
+		v.Settle()
+	}
+}
+
+// Update updates all the Asteroids
+func (as Asteroids) Update(w *World) {
+	for _, v := range as {
+		v.Update(w)
+	}
+	as.collide(w)
+}
+
+// Alive returns true if any Asteroids are alive
+func (as Asteroids) Alive() bool {
+	for _, v := range as {
+		if v.Alive {
+			return true
+		}
+	}
+	return false
+}
+
+// InPlay is how many asteroids are alive and have been released
+func (as Asteroids) InPlay() int {
+	n := 0
+	for _, v := range as {
+		if v.Alive && !v.Waiting {
+			n++
+		}
+	}
+	return n
+}
+
+// Nearest is the hittable asteroid closest to p, as long as it's within the
+// given distance and not one of the exceptions, or nil if there isn't one
+func (as Asteroids) Nearest(p Vec, within float64, except Asteroids) *Asteroid {
+	var nearest *Asteroid
+	for _, v := range as {
+		if !v.Hittable() || except.Contains(v) {
+			continue
+		}
+		d := v.Center.Sub(p).Len()
+		if d <= within {
+			nearest, within = v, d
+		}
+	}
+	return nearest
+}
+
+// Along is every hittable asteroid the line from a to b, widened by width on
+// either side, passes through, nearest to a first
+func (as Asteroids) Along(a, b Vec, width float64) Asteroids {
+	var along byDistance
+	as.along(&along, a, b, width)
+	return along.as
+}
+
+// along is Along putting what it finds in buf, to reuse it
+func (as Asteroids) along(buf *byDistance, a, b Vec, width float64) {
+	buf.as, buf.distances = buf.as[:0], buf.distances[:0]
+	for _, v := range as {
+		if !v.Hittable() {
+			continue
+		}
+		if crosses, d := v.Crosses(a, b, width); crosses {
+			buf.as = append(buf.as, v)
+			buf.distances = append(buf.distances, d)
+		}
+	}
+	sort.Sort(buf)
+}
+
+// byDistance sorts asteroids by how far away they are
+type byDistance struct {
+	as        Asteroids
+	distances []float64
+}
+
+func (b byDistance) Len() int           { return len(b.as) }
+func (b byDistance) Less(i, j int) bool { return b.distances[i] < b.distances[j] }
+func (b byDistance) Swap(i, j int) {
+	b.as[i], b.as[j] = b.as[j], b.as[i]
+	b.distances[i], b.distances[j] = b.distances[j], b.distances[i]
+}
+
+// Contains reports whether a is one of the Asteroids
+func (as Asteroids) Contains(a *Asteroid) bool {
+	for _, v := range as {
+		if v == a {
+			return true
+		}
+	}
+	return false
+}
+
+// Impacting returns true if any Asteroids are impacting
+func (as Asteroids) Impacting() bool {
+	for _, v := range as {
+		if v.Impacting {
+			return true
+		}
+	}
+	return false
+}
+
+// ExplosionFrames is how many ticks an Explosion lasts
+const ExplosionFrames int = 7
+
+// An Explosion is an animated impact explosion
+type Explosion struct {
+	*Object
+	Frame     int
+	Exploding bool
+	Done      bool
+}
+
+// NewExplosion makes a new Explosion that hasn't gone off yet
+func NewExplosion(radius float64) *Explosion {
+	return &Explosion{
+		Object:    NewObject(radius),
+		Frame:     1,
+		Exploding: false,
+		Done:      false,
+	}
+}
+
+// Update sets positioning and animation for Explosions, which go wherever
+// what's exploding goes
+func (o *Explosion) Update(at *Object) {
+	o.Center, o.Last = at.Center, at.Last
+
+	if o.Exploding {
+		if o.Frame < ExplosionFrames {
+			o.Frame++
+		} else {
+			o.Frame = 1
+			o.Exploding = false
+			o.Done = true
+		}
+	}
+}
+
+// The Crosshair is a target showing where the the player will shoot
+type Crosshair struct {
+	*Object
+	CoolingDown   bool
+	Shooting      bool
+	Missing       bool
+	Player        int   // which player aims it, counting from zero
+	Score         int   // this player's share of the score
+	Combo         int   // how many asteroids the last shot hit
+	Turret        int   // which moon's turret fires, counting from one, or 0 for the nearest
+	Firing        *Moon // the moon whose turret fired last
+	ShootingFrom  Vec
+	ShootingTo    Vec   // where the laser stopped
+	Targets       []Vec // where extra shots from multi-shot went
+	Explosion     *Explosion
+	CooldownLevel int    // how much faster a miss cools down
+	ReachLevel    int    // how much wider the laser hits
+	cooled        func() // ends the cooldown, made once so missing doesn't allocate
+}
+
+// Update recalculates the crosshair position
+func (o *Crosshair) Update(w *World) {
+	o.Shooting = false
+	o.Missing = false
+
+	aim := w.Input.Aim(o.Player)
+	o.Center = FromPoint(aim.Cursor)
+	o.Settle()
+	for _, m := range w.Moons {
+		m.Turret.Update(w) // so it's pointing where the player is aiming now
+	}
+
+	// Pick a turret, or pick it again to go back to the nearest one
+	if choice := w.Input.Turret; o.Player == 0 && w.State == Playing && choice > 0 && choice <= len(w.Moons) {
+		if o.Turret == choice {
+			o.Turret = 0
+		} else {
+			o.Turret = choice
+		}
+	}
+
+	canShoot := w.State == Playing && !o.CoolingDown
+	if canShoot && aim.Clicked {
+		o.Missing = true
+		o.Shooting = true
+		w.Emit(LaserFired)
+
+		// The laser goes from the turret, the way it's pointing, off the edge
+		// of the screen or into the first asteroid in its way
+		o.Firing = o.Moon(w)
+		turret := o.Firing.Turret
+		turret.Aim(o.Center)
+		length := math.Hypot(float64(w.Width), float64(w.Height))
+		o.ShootingFrom = turret.Center
+		o.ShootingTo = turret.Center.Sub(Polar(length, turret.Angle))
+		width := o.Width(w)
+		hit := w.Along(o.ShootingFrom, o.ShootingTo, width)
+		if len(hit) > 0 && !turret.Piercing {
+			hit = hit[:1]
+			o.ShootingTo = hit[0].Center
+		}
+
+		for _, v := range hit {
+			o.Missing = false
+			o.hit(w, v)
+		}
+
+		// Multi-shot fires at the asteroids nearest the crosshair too
+		o.Targets = o.Targets[:0]
+		for i := 0; i < turret.Shots; i++ {
+			v := w.Nearest(o.Center, o.Radius*MultiShotRange, hit)
+			if v == nil {
+				break
+			}
+			o.Missing = false
+			hit = append(hit, v)
+			o.Targets = append(o.Targets, v.Center)
+			o.hit(w, v)
+		}
+		hits := len(hit)
+
+		// Power-ups are picked up last so that a smart bomb doesn't take
+		// the asteroids the laser hit
+		for _, v := range w.PowerUps {
+			if crosses, _ := v.Crosses(o.ShootingFrom, o.ShootingTo, width); v.Alive && crosses {
+				o.Missing = false
+				w.Collect(v)
+			}
+		}
+
+		// Fragments only join in now so that multi-shot doesn't shoot them
+		w.addFragments()
+		o.Combo = hits
+		o.AddScore(w, ShotPoints(hits))
+	}
+
+	if o.Missing {
+		o.AddScore(w, -PointsMissed)
+		o.Explosion.Exploding = true
+		cooldown := time.Duration(float64(time.Second) * math.Pow(CooldownUpgrade, float64(o.CooldownLevel)))
+		if w.Effects.Active(RapidFire) {
+			cooldown = time.Duration(float64(cooldown) * RapidFireRatio)
+		}
+		if !w.Effects.Active(NoCooldown) {
+			o.CoolingDown = true
+			w.Scheduler.After(Ticks(cooldown), o.cooled)
+		}
+	}
+
+	o.Explosion.Update(o.Firing.Object)
+}
+
+// Moon is the moon whose turret fires next, the one the player picked or
+// else the one nearest the crosshair
+func (o *Crosshair) Moon(w *World) *Moon {
+	if o.Turret > 0 && o.Turret <= len(w.Moons) {
+		return w.Moons[o.Turret-1]
+	}
+	return w.Moons.Nearest(o.Center)
+}
+
+// hit shoots an asteroid, destroying it if that was the last of its hit
+// points, any fragments it splits into are kept in the World's fragments
+func (o *Crosshair) hit(w *World, v *Asteroid) {
+	v.HitPoints--
+	if o.Firing.Piercing {
+		v.HitPoints = 0
+	}
+	if v.HitPoints > 0 {
+		w.Emit(AsteroidDamaged)
+		return
+	}
+	w.Emit(AsteroidShot)
+	w.Scheduler.After(Ticks(time.Millisecond*100), w.shotExploded)
+	w.destroy(v)
+}
+
+// Width is how far either side of the laser it hits things, which is wider
+// with a bigger crosshair and with the wide laser
+func (o *Crosshair) Width(w *World) float64 {
+	width := LaserWidth * (1 + ReachUpgrade*float64(o.ReachLevel))
+	if w.Effects.Active(WideLaser) {
+		width *= WideLaserRatio
+	}
+	return width
+}
+
+// destroy blows up a shot asteroid, which sometimes leaves a power-up behind,
+// any fragments it splits into are kept in the World's fragments
+func (w *World) destroy(v *Asteroid) {
+	v.Explosion.Exploding = true
+	w.Count--
+	w.Credits += CreditsPerAsteroid
+	w.Drop(v)
+	w.fragments = v.Split(w.Pool, w.Sizes, w.fragments)
+}
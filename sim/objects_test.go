@@ -0,0 +1,292 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestOverlaps(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b Object
+		want bool
+	}{
+		{"same place", Object{Center: Vec{0, 0}, Radius: 1}, Object{Center: Vec{0, 0}, Radius: 1}, true},
+		{"touching", Object{Center: Vec{0, 0}, Radius: 5}, Object{Center: Vec{10, 0}, Radius: 5}, true},
+		{"apart", Object{Center: Vec{0, 0}, Radius: 5}, Object{Center: Vec{11, 0}, Radius: 5}, false},
+		{"diagonal", Object{Center: Vec{0, 0}, Radius: 3}, Object{Center: Vec{3, 4}, Radius: 2}, true},
+	}
+	for _, c := range cases {
+		if got := c.a.Overlaps(&c.b); got != c.want {
+			t.Errorf("%s: Overlaps() = %v, want %v", c.name, got, c.want)
+		}
+		if got := c.b.Overlaps(&c.a); got != c.want {
+			t.Errorf("%s: reverse Overlaps() = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCrosses(t *testing.T) {
+	a, b := Vec{0, 0}, Vec{100, 0}
+	cases := []struct {
+		name  string
+		o     Object
+		width float64
+		want  bool
+		along float64
+	}{
+		{"on the line", Object{Center: Vec{50, 0}, Radius: 5}, 0, true, 50},
+		{"touching", Object{Center: Vec{50, 5}, Radius: 5}, 0, true, 50},
+		{"beside", Object{Center: Vec{50, 6}, Radius: 5}, 0, false, 50},
+		{"beside a wide line", Object{Center: Vec{50, 8}, Radius: 5}, 3, true, 50},
+		{"behind the start", Object{Center: Vec{-4, 0}, Radius: 5}, 0, true, 0},
+		{"past the end", Object{Center: Vec{106, 0}, Radius: 5}, 0, false, 100},
+		{"at the end", Object{Center: Vec{104, 3}, Radius: 5}, 0, true, 100},
+	}
+	for _, c := range cases {
+		got, along := c.o.Crosses(a, b, c.width)
+		if got != c.want {
+			t.Errorf("%s: Crosses() = %v, want %v", c.name, got, c.want)
+		}
+		if along != c.along {
+			t.Errorf("%s: closest %v along the line, want %v", c.name, along, c.along)
+		}
+	}
+
+	point := Object{Center: Vec{3, 4}, Radius: 5}
+	if got, along := point.Crosses(Vec{}, Vec{}, 0); !got || along != 0 {
+		t.Errorf("a line with no length = %v, %v, want the same as a point", got, along)
+	}
+}
+
+func TestAlong(t *testing.T) {
+	as := Asteroids{
+		{Object: &Object{Center: Vec{80, 0}, Radius: 5}, Explosion: NewExplosion(1), Alive: true},
+		{Object: &Object{Center: Vec{20, 0}, Radius: 5}, Explosion: NewExplosion(1), Alive: true},
+		{Object: &Object{Center: Vec{50, 30}, Radius: 5}, Explosion: NewExplosion(1), Alive: true},
+		{Object: &Object{Center: Vec{40, 0}, Radius: 5}, Explosion: NewExplosion(1), Alive: false},
+	}
+	got := as.Along(Vec{0, 0}, Vec{100, 0}, 2)
+	if len(got) != 2 || got[0] != as[1] || got[1] != as[0] {
+		t.Errorf("Along() = %v, want the two living asteroids on the line, nearest first", got)
+	}
+}
+
+func TestLaserFirstHit(t *testing.T) {
+	for _, piercing := range []bool{false, true} {
+		w := newTestWorld(t)
+		w.Moon.Piercing = piercing
+		w.Asteroids[0].Alive = false
+
+		// Line two asteroids up between the Moon and the crosshair
+		w.Update(Input{})
+		angle := w.Moon.Center.Sub(w.Earth.Center).Angle()
+		distance := float64(w.Width) // further out than the Moon
+		near := NewAsteroid(AsteroidTypeByName("Normal"), w.Sizes, angle, distance/2)
+		far := NewAsteroid(AsteroidTypeByName("Normal"), w.Sizes, angle, distance)
+		w.AddAsteroids(Asteroids{far, near})
+		w.Update(Input{})
+		w.Update(Input{Cursor: far.Center.Point(), Clicked: true})
+
+		if !near.Explosion.Exploding {
+			t.Errorf("piercing %v: laser missed the first asteroid", piercing)
+		}
+		if far.Explosion.Exploding != piercing {
+			t.Errorf("piercing %v: asteroid behind the first exploding %v", piercing, far.Explosion.Exploding)
+		}
+		if !piercing && w.Crosshair.ShootingTo != near.Center {
+			t.Errorf("laser stopped at %v, want the first asteroid at %v", w.Crosshair.ShootingTo, near.Center)
+		}
+	}
+}
+
+func TestExplosionUpdate(t *testing.T) {
+	e := NewExplosion(1)
+	e.Exploding = true
+	for i := 1; i < ExplosionFrames; i++ {
+		e.Update(&Object{Center: Vec{1, 2}})
+		if e.Done {
+			t.Fatalf("explosion done after %d ticks", i)
+		}
+	}
+	e.Update(&Object{Center: Vec{1, 2}})
+	if !e.Done || e.Exploding {
+		t.Errorf("explosion not done after %d ticks", ExplosionFrames)
+	}
+	if e.Center != (Vec{1, 2}) {
+		t.Errorf("explosion at %v, want (1,2)", e.Center)
+	}
+}
+
+// crowdedWorld is a world with n asteroids in play scattered all over it
+func crowdedWorld(n int) *World {
+	w := NewWorld(1280, 960, DefaultSizes, 1)
+	rng := rand.New(rand.NewSource(int64(n)))
+	as := make(Asteroids, n)
+	for i := range as {
+		angle := rng.Float64() * math.Pi * 2
+		distance := rng.Float64() * float64(w.Width) / 2
+		as[i] = NewAsteroid(AsteroidTypeByName("Normal"), w.Sizes, angle, distance)
+		as[i].locate(w)
+	}
+	w.AddAsteroids(as)
+	return w
+}
+
+func TestGrid(t *testing.T) {
+	w := crowdedWorld(500)
+	rng := rand.New(rand.NewSource(2))
+	point := func() Vec { return Vec{rng.Float64() * float64(w.Width), rng.Float64() * float64(w.Height)} }
+
+	for i := 0; i < 100; i++ {
+		a, b := point(), point()
+		got, want := w.Along(a, b, LaserWidth), w.Asteroids.Along(a, b, LaserWidth)
+		if len(got) != len(want) {
+			t.Fatalf("Along(%v, %v) found %d asteroids, want %d", a, b, len(got), len(want))
+		}
+		for j := range got {
+			// Asteroids the same distance along can come in either order
+			_, gotAlong := got[j].Crosses(a, b, LaserWidth)
+			_, wantAlong := want[j].Crosses(a, b, LaserWidth)
+			if !want.Contains(got[j]) || gotAlong != wantAlong {
+				t.Fatalf("Along(%v, %v) found different asteroids", a, b)
+			}
+		}
+
+		p := point()
+		if got, want := w.Nearest(p, 100, nil), w.Asteroids.Nearest(p, 100, nil); got != want {
+			t.Fatalf("Nearest(%v) = %v, want %v", p, got, want)
+		}
+
+		moon := Object{Center: p, Radius: w.Sizes.Moon}
+		near := w.Near(p, moon.Radius)
+		for _, v := range w.Asteroids {
+			if moon.Overlaps(v.Object) && !near.Contains(v) {
+				t.Fatalf("Near(%v) missed an asteroid at %v", p, v.Center)
+			}
+		}
+	}
+
+	// Anything moving makes it rebuild before it's used again
+	v := w.Asteroids[0]
+	v.Position = Vec{}
+	v.locate(w)
+	if !w.Near(w.Earth.Center, 1).Contains(v) {
+		t.Errorf("moved asteroid not found where it went")
+	}
+}
+
+// benchmarkCounts are how many asteroids to benchmark with, the number of
+// asteroids grows with every wave
+var benchmarkCounts = []int{100, 1000, 5000}
+
+// BenchmarkGridBuild is sorting the asteroids into the Grid, which happens
+// once a tick before the first query, the query benchmarks after it leave it
+// built like it is for the rest of the tick
+func BenchmarkGridBuild(b *testing.B) {
+	for _, n := range benchmarkCounts {
+		w := crowdedWorld(n)
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				w.Grid.Build(w.Asteroids)
+			}
+		})
+	}
+}
+
+func BenchmarkAlong(b *testing.B) {
+	for _, n := range benchmarkCounts {
+		w := crowdedWorld(n)
+		from, to := w.Moon.Center, Vec{float64(w.Width), float64(w.Height)}
+		b.Run(fmt.Sprintf("naive-%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				w.Asteroids.Along(from, to, LaserWidth)
+			}
+		})
+		b.Run(fmt.Sprintf("grid-%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				w.Along(from, to, LaserWidth)
+			}
+		})
+	}
+}
+
+func BenchmarkOverlaps(b *testing.B) {
+	for _, n := range benchmarkCounts {
+		w := crowdedWorld(n)
+		moon := w.Moon.Object
+		b.Run(fmt.Sprintf("naive-%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, v := range w.Asteroids {
+					moon.Overlaps(v.Object)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("grid-%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, v := range w.Near(moon.Center, moon.Radius) {
+					moon.Overlaps(v.Object)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkCollide is every asteroid checking for crashes into every other,
+// the worst case when they've all been knocked, including the rebuild
+func BenchmarkCollide(b *testing.B) {
+	for _, n := range benchmarkCounts {
+		w := crowdedWorld(n)
+		b.Run(fmt.Sprintf("naive-%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, a := range w.Asteroids {
+					for _, v := range w.Asteroids {
+						a.Overlaps(v.Object)
+					}
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("grid-%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				w.Grid.Stale = true
+				for _, a := range w.Asteroids {
+					for _, v := range w.Near(a.Center, a.Radius) {
+						a.Overlaps(v.Object)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestBetween(t *testing.T) {
+	w := newTestWorld(t)
+	a := w.Asteroids[0]
+	was := a.Center
+	w.Update(Input{})
+	if a.Last != was || a.Between(0) != was || a.Between(1) != a.Center {
+		t.Errorf("drawn from %v to %v, want from %v to %v", a.Between(0), a.Between(1), was, a.Center)
+	}
+	if mid := a.Between(0.5); mid != was.Add(a.Center).Mul(0.5) {
+		t.Errorf("drawn at %v half way through the tick", mid)
+	}
+
+	// Anything just released starts where it was placed
+	released := w.Asteroids[w.Spawner.Next]
+	for released.Waiting {
+		w.Update(Input{})
+	}
+	if d := released.Center.Sub(released.Last).Len(); d > released.Speed*2 {
+		t.Errorf("released asteroid drawn moving %v in a tick, want from where it was placed", d)
+	}
+
+	// Nothing moves while the game's paused
+	w.Update(Input{Pause: true})
+	w.Update(Input{})
+	if a.Last != a.Center || w.Moon.Last != w.Moon.Center {
+		t.Errorf("paused asteroid drawn from %v to %v", a.Last, a.Center)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+package sim
+
+import (
+	"math"
+	"math/rand"
+)
+
+var (
+	EarthGravity float64 = 250  // how hard the Earth pulls asteroids in
+	MoonGravity  float64 = 40   // how hard the Moon pulls asteroids passing by
+	SpiralPitch  float64 = 0.35 // how much of a spiralling asteroid's speed takes it inwards
+	CurveAim     float64 = 0.8  // how far off-centre curving asteroids aim, in Earth radii
+	Leash        float64 = 2    // how many times further than the edge of the screen asteroids can go before turning back
+)
+
+// A Path is the way an asteroid travels towards the Earth
+type Path int
+
+const (
+	// Direct asteroids head straight for the Earth
+	Direct Path = iota
+	// Curved asteroids aim to one side and are bent in by gravity
+	Curved
+	// Spiral asteroids circle the Earth, getting closer each time round
+	Spiral
+)
+
+// Paths are the names of each Path, for config files
+var Paths = []string{"Direct", "Curved", "Spiral"}
+
+func (p Path) String() string {
+	return Paths[p]
+}
+
+// PathByName finds a Path, or reports false if there isn't one with that name
+func PathByName(name string) (Path, bool) {
+	for i, v := range Paths {
+		if v == name {
+			return Path(i), true
+		}
+	}
+	return Direct, false
+}
+
+// Place puts an asteroid at the given angle and distance from the surface of
+// an Earth with the given radius, heading straight for it
+func (o *Asteroid) Place(angle, distance, earthRadius float64) {
+	o.Angle, o.Distance = angle, distance
+	o.Position = Polar(distance+earthRadius, angle)
+	o.Velocity = Polar(-o.Speed, angle)
+}
+
+// Launch sets an asteroid off along the Path of its type, picking which way
+// it curves using rng
+func (o *Asteroid) Launch(rng *rand.Rand, earthRadius float64) {
+	o.Turn = 1
+	if rng.Intn(2) == 0 {
+		o.Turn = -1
+	}
+
+	switch o.Type.Path {
+	case Curved:
+		// Aim past the middle of the Earth, gravity does the rest
+		aim := o.Turn * (0.5 + rng.Float64()/2) * CurveAim * earthRadius
+		p, r := o.Position, o.Position.Len()
+		heading := Vec{-p.X - p.Y/r*aim, -p.Y + p.X/r*aim}
+		o.Velocity = heading.Mul(o.Speed / heading.Len())
+	case Spiral:
+		o.steer()
+	}
+}
+
+// steer points a spiralling asteroid round and in
+func (o *Asteroid) steer() {
+	p, r := o.Position, o.Position.Len()
+	inwards := SpiralPitch * o.Speed
+	round := math.Sqrt(1-SpiralPitch*SpiralPitch) * o.Speed * o.Turn
+	o.Velocity = Vec{
+		(-p.X*inwards - p.Y*round) / r,
+		(-p.Y*inwards + p.X*round) / r,
+	}
+}
+
+// pull accelerates an asteroid towards a body the given distance away
+// (relative to the asteroid) with the given gravity
+func (o *Asteroid) pull(to Vec, gravity float64) {
+	d2 := to.Dot(to)
+	if d2 < 1 {
+		return
+	}
+	a := gravity / d2
+	o.Velocity = o.Velocity.Add(to.Mul(a / math.Sqrt(d2)))
+}
+
+// move takes an asteroid one tick along its path, pulled by the Earth and the
+// moons going round it, dt is how much of a tick passes, less than one to
+// slow things down
+func (o *Asteroid) move(earth *Object, moons Moons, dt float64) {
+	earthRadius := earth.Radius
+	if o.Type.Path == Spiral && o.Careering == 0 {
+		o.steer()
+	} else {
+		o.pull(o.Position.Mul(-1), EarthGravity*dt)
+		for _, m := range moons {
+			o.pull(m.Center.Sub(earth.Center).Sub(o.Position), MoonGravity*dt)
+		}
+	}
+
+	// Anything flung too far away comes back
+	r := o.Position.Len()
+	if r-earthRadius > earthRadius*EdgeOfScreenOffset*Leash {
+		o.Velocity = o.Position.Mul(-o.Velocity.Len() / r)
+	}
+
+	o.Position = o.Position.Add(o.Velocity.Mul(dt))
+	o.Angle = o.Position.Angle()
+	o.Distance = o.Position.Len() - earthRadius
+}
@@ -0,0 +1,106 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// fly moves an asteroid on its own until it hits the Earth, reporting how far
+// round the Earth it went on the way
+func fly(t *testing.T, w *World, a *Asteroid) (turned float64) {
+	t.Helper()
+	last := a.Angle
+	for i := 0; i < TPS*60; i++ {
+		a.Update(w)
+		turned += math.Remainder(a.Angle-last, math.Pi*2)
+		last = a.Angle
+		if a.Explosion.Exploding {
+			return turned
+		}
+	}
+	t.Fatalf("%s asteroid never hit the Earth, %v away", a.Type.Path, a.Distance)
+	return
+}
+
+func TestPathByName(t *testing.T) {
+	for i, name := range Paths {
+		if p, ok := PathByName(name); !ok || p != Path(i) || p.String() != name {
+			t.Errorf("PathByName(%q) = %v, %v", name, p, ok)
+		}
+	}
+	if _, ok := PathByName("Wobbly"); ok {
+		t.Errorf("found a path that doesn't exist")
+	}
+}
+
+func TestPaths(t *testing.T) {
+	defer func(g float64) { MoonGravity = g }(MoonGravity)
+	MoonGravity = 0
+	w := newTestWorld(t)
+	rng := rand.New(rand.NewSource(1))
+
+	cases := []struct {
+		path    Path
+		minTurn float64
+		maxTurn float64
+	}{
+		{Direct, 0, 1e-9},
+		{Curved, 0.05, math.Pi},
+		{Spiral, math.Pi / 2, math.Inf(1)},
+	}
+	for _, c := range cases {
+		a := NewAsteroid(&AsteroidType{Name: c.path.String(), Scale: 1, Speed: 1, Path: c.path}, w.Sizes, 1, 300)
+		a.Launch(rng, w.Earth.Radius)
+		turned := fly(t, w, a)
+		if math.Abs(turned) < c.minTurn || math.Abs(turned) > c.maxTurn {
+			t.Errorf("%s asteroid turned %v round the Earth, want %v to %v", c.path, turned, c.minTurn, c.maxTurn)
+		}
+	}
+}
+
+func TestGravityBends(t *testing.T) {
+	defer func(g float64) { MoonGravity = g }(MoonGravity)
+	MoonGravity = 0
+	w := newTestWorld(t)
+
+	// Falling straight in speeds up
+	a := NewAsteroid(AsteroidTypeByName("Normal"), w.Sizes, 0, 300)
+	fly(t, w, a)
+	if speed := a.Velocity.Len(); speed <= a.Speed {
+		t.Errorf("asteroid hit the Earth at %v, no faster than it started", speed)
+	}
+}
+
+func TestMoonSlingshot(t *testing.T) {
+	w := newTestWorld(t)
+	passing := func(gravity float64) *Asteroid {
+		defer func(g float64) { MoonGravity = g }(MoonGravity)
+		MoonGravity = gravity
+
+		// Just miss the Moon on the way past
+		a := NewAsteroid(AsteroidTypeByName("Normal"), w.Sizes, 0, 300)
+		moon := w.Earth.Center
+		moon.X += w.Earth.Radius + 200
+		moon.Y += w.Moon.Radius + a.Radius + 5
+		w.Moon.Center = moon
+		for i := 0; i < 150; i++ {
+			a.Update(w)
+		}
+		return a
+	}
+	without, with := passing(0), passing(MoonGravity*4)
+	if with.Position.Y <= without.Position.Y {
+		t.Errorf("the Moon didn't pull a passing asteroid towards it, y %v without and %v with", without.Position.Y, with.Position.Y)
+	}
+}
+
+func TestLeash(t *testing.T) {
+	w := newTestWorld(t)
+	a := NewAsteroid(AsteroidTypeByName("Normal"), w.Sizes, 0, w.Earth.Radius*EdgeOfScreenOffset*Leash+10)
+	a.Velocity = Vec{5, 0} // flung outwards
+	a.Update(w)
+	if a.Velocity.X >= 0 {
+		t.Errorf("asteroid still heading away at %v after going past the leash", a.Velocity)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+package sim
+
+import "time"
+
+var (
+	MoonKnockback bool    = false // whether the Moon knocks asteroids away instead of destroying them
+	MoonMass      float64 = 20    // how heavy the Moon is compared to a normal asteroid
+	AsteroidMass  float64 = 1     // how heavy a normal asteroid is, bigger ones are heavier
+	Restitution   float64 = 0.8   // how bouncy collisions are, from 0 for not at all to 1 for perfectly
+	KnockTime     float64 = 3     // how many seconds a knocked asteroid can crash into others for
+)
+
+// Mass is how heavy an asteroid is, bigger ones are heavier
+func (o *Asteroid) Mass() float64 {
+	return AsteroidMass * o.Type.Scale * o.Type.Scale
+}
+
+// normal is which way b is from a, as a unit vector
+func normal(a, b Vec) Vec {
+	d := b.Sub(a)
+	length := d.Len()
+	if length == 0 {
+		return Vec{1, 0}
+	}
+	return d.Mul(1 / length)
+}
+
+// impulse is how hard two bodies of mass ma and mb push each other apart
+// along the normal n when b moves at rv relative to a, it's zero if they're
+// already moving apart
+func impulse(n, rv Vec, ma, mb float64) float64 {
+	closing := rv.Dot(n)
+	if closing >= 0 {
+		return 0
+	}
+	return -(1 + Restitution) * closing / (1/ma + 1/mb)
+}
+
+// Knock bounces an asteroid off a moon, which stays in its orbit, sending
+// it careering into anything in its way
+func (w *World) Knock(m *Moon, v *Asteroid) {
+	mp := m.Center.Sub(w.Earth.Center)
+	n := normal(mp, v.Position)
+
+	j := impulse(n, v.Velocity.Sub(m.Velocity), MoonMass, v.Mass())
+	v.Velocity = v.Velocity.Add(n.Mul(j / v.Mass()))
+
+	// Push it out so it doesn't get knocked again next tick
+	v.Position = mp.Add(n.Mul(m.Radius + v.Radius + 1))
+	v.locate(w)
+	v.Careering = Ticks(time.Duration(KnockTime * float64(time.Second)))
+	w.Emit(AsteroidKnocked)
+}
+
+// collide crashes careering asteroids into any others they run into, both
+// get damaged and the survivors bounce off each other and go careering
+// too, which can set off a chain reaction
+func (as Asteroids) collide(w *World) {
+	for _, a := range as {
+		if a.Careering == 0 || !a.Hittable() {
+			continue
+		}
+		for _, b := range w.Near(a.Center, a.Radius) {
+			if a == b || !b.Hittable() || !a.Hittable() || !a.Overlaps(b.Object) {
+				continue
+			}
+
+			n := normal(a.Position, b.Position)
+			impulse := impulse(n, b.Velocity.Sub(a.Velocity), a.Mass(), b.Mass())
+			a.Velocity = a.Velocity.Sub(n.Mul(impulse / a.Mass()))
+			b.Velocity = b.Velocity.Add(n.Mul(impulse / b.Mass()))
+
+			// Push them apart, lighter ones further
+			overlap := a.Radius + b.Radius + 1 - b.Position.Sub(a.Position).Len()
+			share := a.Mass() / (a.Mass() + b.Mass())
+			a.Position = a.Position.Sub(n.Mul(overlap * (1 - share)))
+			b.Position = b.Position.Add(n.Mul(overlap * share))
+			a.locate(w)
+			b.locate(w)
+
+			w.Emit(AsteroidKnocked)
+			b.Careering = a.Careering
+			for _, v := range []*Asteroid{a, b} {
+				v.HitPoints--
+				if v.HitPoints <= 0 {
+					w.Ram(v)
+				}
+			}
+		}
+	}
+}
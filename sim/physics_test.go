@@ -0,0 +1,101 @@
+package sim
+
+import "testing"
+
+// knockWorld is a world where the Moon knocks asteroids away, and an asteroid
+// sat on the outside edge of the Moon, still there after they've both moved
+func knockWorld(t *testing.T) (*World, *Asteroid) {
+	t.Helper()
+	w := newTestWorld(t)
+	w.Asteroids[0].Alive = false
+	w.Moon.Phase = RotationSpeed / MoonOrbitRatio // so the Moon is at angle zero after the tick
+	a := NewAsteroid(AsteroidTypeByName("Normal"), w.Sizes, 0, w.Moon.Radius*(MoonOrbitDistance+0.5))
+	w.AddAsteroids(Asteroids{a})
+	return w, a
+}
+
+func TestKnockback(t *testing.T) {
+	defer func(k bool) { MoonKnockback = k }(MoonKnockback)
+	MoonKnockback = true
+
+	w, a := knockWorld(t)
+	count := w.Count
+	w.Update(Input{})
+	if !hasEvent(w, AsteroidKnocked) || hasEvent(w, AsteroidRammed) {
+		t.Fatalf("events = %v, want the asteroid knocked and not rammed", w.Events)
+	}
+	if !a.Hittable() || w.Count != count {
+		t.Errorf("knocked asteroid was destroyed")
+	}
+	if a.Velocity.X <= 0 || a.Careering == 0 {
+		t.Errorf("asteroid moving at %v after being knocked, careering %d", a.Velocity, a.Careering)
+	}
+}
+
+func TestRamWithoutKnockback(t *testing.T) {
+	w, a := knockWorld(t)
+	w.Update(Input{})
+	if !hasEvent(w, AsteroidRammed) || a.Hittable() {
+		t.Errorf("the Moon didn't destroy the asteroid without knockback")
+	}
+}
+
+func TestRestitution(t *testing.T) {
+	defer func(k bool, r float64) { MoonKnockback, Restitution = k, r }(MoonKnockback, Restitution)
+	MoonKnockback = true
+	speed := func(restitution float64) float64 {
+		Restitution = restitution
+		w, a := knockWorld(t)
+		w.Update(Input{})
+		return a.Velocity.Len()
+	}
+	if dead, bouncy := speed(0), speed(1); dead >= bouncy {
+		t.Errorf("bounced off at %v with no restitution, %v with full restitution", dead, bouncy)
+	}
+}
+
+func TestChainReaction(t *testing.T) {
+	w := newTestWorld(t)
+	armoured := AsteroidTypeByName("Armoured")
+	a := NewAsteroid(armoured, w.Sizes, 0, 300)
+	b := NewAsteroid(armoured, w.Sizes, 0, 300+a.Radius*2-1)
+	c := NewAsteroid(armoured, w.Sizes, 0, 300+a.Radius*4-2)
+	a.Careering = 10
+	a.Velocity.X = -1 // heading for b, which is heading the other way
+	as := Asteroids{a, b, c}
+	w.AddAsteroids(as)
+	as.collide(w)
+
+	if a.HitPoints != armoured.HitPoints-1 || b.HitPoints != armoured.HitPoints-2 || c.HitPoints != armoured.HitPoints-1 {
+		t.Errorf("hit points %d, %d, %d after a chain reaction", a.HitPoints, b.HitPoints, c.HitPoints)
+	}
+	if b.Careering == 0 || c.Careering == 0 {
+		t.Errorf("knocked asteroids aren't careering")
+	}
+}
+
+func TestCollisionsDestroy(t *testing.T) {
+	w := newTestWorld(t)
+	a := NewAsteroid(AsteroidTypeByName("Normal"), w.Sizes, 0, 300)
+	b := NewAsteroid(AsteroidTypeByName("Normal"), w.Sizes, 0, 310)
+	a.Careering = 10
+	w.AddAsteroids(Asteroids{a, b})
+	a.locate(w)
+	b.locate(w)
+	count, score := w.Count, w.Score
+	Asteroids{a, b}.collide(w)
+	if a.Hittable() || b.Hittable() {
+		t.Errorf("asteroids survived crashing into each other")
+	}
+	if w.Count != count-2 || w.Score != score+PointsRammed*2 {
+		t.Errorf("count %d, score %d after two asteroids crashed", w.Count, w.Score)
+	}
+}
+
+func TestMass(t *testing.T) {
+	small := NewAsteroid(AsteroidTypeByName("Small"), DefaultSizes, 0, 0)
+	large := NewAsteroid(AsteroidTypeByName("Large"), DefaultSizes, 0, 0)
+	if small.Mass() >= large.Mass() {
+		t.Errorf("small asteroid weighs %v, large one %v", small.Mass(), large.Mass())
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+package sim
+
+import "image"
+
+var CoOp bool = false // whether a second player joins in with their own crosshair
+
+// Aim is where one player is aiming and whether they're shooting
+type Aim struct {
+	Cursor  image.Point
+	Clicked bool
+}
+
+// Aim is what the given player, counting from zero, is doing with their
+// crosshair this tick
+func (in Input) Aim(player int) Aim {
+	if player == 0 {
+		return Aim{Cursor: in.Cursor, Clicked: in.Clicked}
+	}
+	return in.Player2
+}
+
+// NewCrosshair makes a crosshair for the given player, counting from zero
+func NewCrosshair(sizes Sizes, player int) *Crosshair {
+	o := &Crosshair{
+		Object:    NewObject(sizes.Crosshair),
+		Explosion: NewExplosion(sizes.Explosion),
+		Player:    player,
+	}
+	o.cooled = func() { o.CoolingDown = false }
+	return o
+}
+
+// Reset gets a crosshair ready for a new game
+func (o *Crosshair) Reset(w *World) {
+	o.Score = 0
+	o.CoolingDown = false
+	o.Explosion.Exploding = false
+	o.Turret = 0
+	o.Firing = w.Moon
+}
+
+// AddScore adds points to the player's score and the team's, neither of which
+// goes below zero
+func (o *Crosshair) AddScore(w *World, points int) {
+	w.AddScore(points)
+	o.Score += points
+	if o.Score < 0 {
+		o.Score = 0
+	}
+}
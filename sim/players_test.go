@@ -0,0 +1,49 @@
+package sim
+
+import (
+	"image"
+	"testing"
+)
+
+func TestCoOp(t *testing.T) {
+	defer func(c bool) { CoOp = c }(CoOp)
+	CoOp = true
+
+	w := newTestWorld(t)
+	if len(w.Crosshairs) != 2 {
+		t.Fatalf("%d crosshairs in co-op, want 2", len(w.Crosshairs))
+	}
+	one, two := w.Crosshairs[0], w.Crosshairs[1]
+	target := w.Asteroids[0]
+	w.Update(Input{})
+
+	// The second player hits and the first misses, in the same tick
+	w.Update(Input{
+		Cursor:  image.Pt(-1000, -1000),
+		Clicked: true,
+		Player2: Aim{Cursor: target.Center.Point(), Clicked: true},
+	})
+	if !target.Explosion.Exploding {
+		t.Errorf("second player's shot missed")
+	}
+	if two.Score != ShotPoints(1) || one.Score != 0 {
+		t.Errorf("scores %d and %d, want only the second player's shot counted", one.Score, two.Score)
+	}
+	if w.Score != two.Score {
+		t.Errorf("team score %d, want %d", w.Score, two.Score)
+	}
+	if !one.CoolingDown || two.CoolingDown {
+		t.Errorf("cooling down %v and %v, want only the first player", one.CoolingDown, two.CoolingDown)
+	}
+
+	// Each player's cooldown is their own
+	w.Update(Input{Player2: Aim{Cursor: image.Pt(-1000, -1000), Clicked: true}})
+	if !hasEvent(w, LaserFired) || !two.CoolingDown {
+		t.Errorf("second player couldn't fire while the first was cooling down")
+	}
+
+	w.StartOver()
+	if one.Score != 0 || two.Score != 0 || two.CoolingDown {
+		t.Errorf("scores %d and %d after starting over", one.Score, two.Score)
+	}
+}
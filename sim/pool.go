@@ -0,0 +1,79 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+package sim
+
+// A Pool keeps asteroids and power-ups that are gone, along with their
+// explosions, so they can be used again instead of making new ones every
+// wave, a nil Pool always makes new ones
+type Pool struct {
+	asteroids Asteroids
+	powerUps  PowerUps
+}
+
+// Asteroid is a living asteroid of the given type, like NewAsteroid makes,
+// reusing a dead one if there is one
+func (p *Pool) Asteroid(t *AsteroidType, sizes Sizes, angle, distance float64) *Asteroid {
+	if p == nil || len(p.asteroids) == 0 {
+		return NewAsteroid(t, sizes, angle, distance)
+	}
+	last := len(p.asteroids) - 1
+	o := p.asteroids[last]
+	p.asteroids[last] = nil
+	p.asteroids = p.asteroids[:last]
+	o.init(t, sizes, angle, distance)
+	return o
+}
+
+// PowerUp is a power-up to be filled in, reusing a collected one if there is
+// one
+func (p *Pool) PowerUp() *PowerUp {
+	if p == nil || len(p.powerUps) == 0 {
+		return &PowerUp{Object: &Object{}}
+	}
+	last := len(p.powerUps) - 1
+	o := p.powerUps[last]
+	p.powerUps[last] = nil
+	p.powerUps = p.powerUps[:last]
+	return o
+}
+
+// Free puts asteroids back in the Pool, they mustn't be used after this
+func (p *Pool) Free(as ...*Asteroid) {
+	if p != nil {
+		p.asteroids = append(p.asteroids, as...)
+	}
+}
+
+// FreePowerUps puts power-ups back in the Pool, they mustn't be used after
+// this
+func (p *Pool) FreePowerUps(ps ...*PowerUp) {
+	if p != nil {
+		p.powerUps = append(p.powerUps, ps...)
+	}
+}
+
+// sweep clears the dead asteroids out of the wave into the Pool, keeping the
+// living ones in the same order and the Spawner's place among them
+func (w *World) sweep() {
+	alive := w.Asteroids[:0]
+	next := w.Spawner.Next
+	for i, v := range w.Asteroids {
+		if v.Alive {
+			alive = append(alive, v)
+			continue
+		}
+		w.Pool.Free(v)
+		if i < w.Spawner.Next {
+			next--
+		}
+	}
+	if len(alive) == len(w.Asteroids) {
+		return
+	}
+	clear(w.Asteroids[len(alive):])
+	w.Asteroids = alive
+	w.Spawner.Next = next
+	w.Grid.Stale = true
+}
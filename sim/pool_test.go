@@ -0,0 +1,126 @@
+package sim
+
+import (
+	"image"
+	"testing"
+)
+
+func TestSweep(t *testing.T) {
+	w := newTestWorld(t)
+	first, second := w.Asteroids[0], w.Asteroids[1]
+	waiting := w.Asteroids[w.Spawner.Next]
+	n := len(w.Asteroids)
+
+	first.Alive = false
+	w.Update(Input{})
+	if len(w.Asteroids) != n-1 || w.Asteroids.Contains(first) || w.Asteroids[0] != second {
+		t.Fatalf("dead asteroid wasn't cleared away")
+	}
+	if w.Asteroids[w.Spawner.Next] != waiting {
+		t.Errorf("spawner lost its place")
+	}
+
+	// The dead one comes back good as new
+	first.Explosion.Done = true
+	first.Explosion.Frame = 4
+	first.Careering = 10
+	again := w.Pool.Asteroid(AsteroidTypeByName("Large"), w.Sizes, 1, 300)
+	if again != first {
+		t.Fatalf("dead asteroid wasn't reused")
+	}
+	fresh := NewAsteroid(AsteroidTypeByName("Large"), w.Sizes, 1, 300)
+	if *again.Object != *fresh.Object || *again.Explosion.Object != *fresh.Explosion.Object ||
+		again.Explosion.Frame != 1 || again.Explosion.Done || again.Careering != 0 ||
+		!again.Alive || again.HitPoints != fresh.HitPoints || again.Distance != fresh.Distance {
+		t.Errorf("reused asteroid %+v, want %+v", again, fresh)
+	}
+}
+
+func TestPoolPowerUps(t *testing.T) {
+	defer func(c float64) { PowerUpChance = c }(PowerUpChance)
+	PowerUpChance = 1
+
+	w := newTestWorld(t)
+	p := addPowerUp(w, Shield, PowerUpSpeed/2)
+	w.Update(Input{})
+	if len(w.PowerUps) != 0 {
+		t.Fatalf("power-up didn't burn up")
+	}
+
+	target := w.Asteroids[0]
+	w.Drop(target)
+	if len(w.PowerUps) != 1 || w.PowerUps[0] != p {
+		t.Fatalf("burnt up power-up wasn't reused")
+	}
+	if !p.Alive || p.Center != target.Center || p.Distance != target.Distance || p.Radius != w.Sizes.PowerUp {
+		t.Errorf("reused power-up %+v %+v", p, p.Object)
+	}
+}
+
+// endlessWorld is a World that plays the same size wave over and over, the
+// Moon rams some asteroids and the shield stops the rest, and the player
+// shoots every so often, alternately at an asteroid and at nothing, it
+// returns a function to play a tick. It's played plenty of waves after every
+// moon joined, so everything has grown to size
+func endlessWorld(tb testing.TB) (*World, func()) {
+	multiplier := WaveMultiplier
+	WaveMultiplier = 1
+	tb.Cleanup(func() { WaveMultiplier = multiplier })
+
+	w := NewWorld(1280, 960, DefaultSizes, 1)
+	w.Update(Input{Clicked: true})
+	w.HowMany = 100
+	w.Restart()
+	ticks := 0
+	tick := func() {
+		ticks++
+		w.Effects[Shield] = TPS
+		in := Input{Cursor: image.Pt(-1000, -1000)}
+		if w.State == WaveIntermission {
+			in.Choice = ShopNextWave + 1
+		} else if ticks%20 == 0 {
+			in.Clicked = true
+			if ticks%40 == 0 {
+				for _, v := range w.Asteroids {
+					if v.Hittable() {
+						in.Cursor = v.Center.Point()
+						break
+					}
+				}
+			}
+		}
+		w.Update(in)
+	}
+	for len(w.Moons) < MaxMoons || w.Wave < ExtraMoonWaves*MaxMoons+20 {
+		tick()
+	}
+	return w, tick
+}
+
+func TestNoAllocations(t *testing.T) {
+	w, tick := endlessWorld(t)
+	wave, score := w.Wave, w.Score
+	play := func() {
+		for i := 0; i < TPS*60; i++ {
+			tick()
+		}
+	}
+	if allocs := testing.AllocsPerRun(5, play); allocs != 0 {
+		t.Errorf("%v allocations a minute of play, want none", allocs)
+	}
+	if w.Wave < wave+2 || w.Score == score {
+		t.Errorf("only got to wave %d scoring %d, want new waves and shots while counting", w.Wave, w.Score)
+	}
+}
+
+// BenchmarkWave is playing a whole wave of 100 asteroids
+func BenchmarkWave(b *testing.B) {
+	w, tick := endlessWorld(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for wave := w.Wave; w.Wave == wave; {
+			tick()
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+package sim
+
+import "time"
+
+var (
+	PowerUpChance   float64 = 0.08 // how likely a destroyed asteroid is to drop a power-up
+	PowerUpDuration float64 = 10   // how many seconds a power-up lasts
+	PowerUpSpeed    float64 = 0.6  // how far power-ups drift towards the Earth each tick
+	RapidFireRatio  float64 = 0.25 // how much of the usual cooldown there is with rapid fire
+	WideLaserRatio  float64 = 2    // how much wider the laser hits with a wide laser
+	SlowMotionRatio float64 = 0.5  // how fast asteroids move in slow motion
+)
+
+// A Power is what a PowerUp does when it's collected
+type Power int
+
+const (
+	// RapidFire makes missed shots cool down faster
+	RapidFire Power = iota
+	// NoCooldown means missed shots don't cool down at all
+	NoCooldown
+	// WideLaser makes the laser hit a wider area
+	WideLaser
+	// Shield stops asteroids hitting the Earth
+	Shield
+	// SlowMotion slows asteroids down
+	SlowMotion
+	// SmartBomb destroys every asteroid there is right away
+	SmartBomb
+	// Powers is how many kinds of Power there are
+	Powers
+)
+
+var powerNames = [Powers]string{"RAPID FIRE", "NO COOLDOWN", "WIDE LASER", "SHIELD", "SLOW MOTION", "SMART BOMB"}
+
+func (p Power) String() string {
+	return powerNames[p]
+}
+
+// A PowerUp drifts towards the Earth until it's collected by shooting it or
+// running it over with the Moon, or it burns up in the atmosphere
+type PowerUp struct {
+	*Object
+	Power    Power
+	Angle    float64
+	Distance float64
+	Alive    bool
+}
+
+// Update moves a PowerUp closer to the Earth
+func (o *PowerUp) Update(w *World) {
+	o.Distance -= PowerUpSpeed
+	if o.Distance <= 0 {
+		o.Alive = false
+	}
+
+	o.Center = w.Earth.Center.Add(Polar(o.Distance+w.Earth.Radius, o.Angle))
+}
+
+// PowerUps are all the power-ups floating around
+type PowerUps []*PowerUp
+
+// Settle marks where all the PowerUps are at the start of a tick
+func (ps PowerUps) Settle() {
+	for _, v := range ps {
+		v.Settle()
+	}
+}
+
+// Update moves all the PowerUps and puts the ones that are gone back in the
+// Pool
+func (ps *PowerUps) Update(w *World) {
+	alive := (*ps)[:0]
+	for _, v := range *ps {
+		v.Update(w)
+		if v.Alive {
+			alive = append(alive, v)
+		} else {
+			w.Pool.FreePowerUps(v)
+		}
+	}
+	clear((*ps)[len(alive):])
+	*ps = alive
+}
+
+// Collide collects any PowerUps the Moon runs over
+func (ps PowerUps) Collide(w *World, m *Moon) {
+	for _, v := range ps {
+		if v.Alive && m.Overlaps(v.Object) {
+			w.Collect(v)
+		}
+	}
+}
+
+// clearPowerUps takes away all the power-ups for a new game
+func (w *World) clearPowerUps() {
+	w.Pool.FreePowerUps(w.PowerUps...)
+	clear(w.PowerUps)
+	w.PowerUps = w.PowerUps[:0]
+}
+
+// Effects are how many ticks each Power has left to run
+type Effects [Powers]int
+
+// Active reports whether the given Power is in effect
+func (e *Effects) Active(p Power) bool {
+	return e[p] > 0
+}
+
+// Update counts down the time left on each Power
+func (e *Effects) Update() {
+	for i := range e {
+		if e[i] > 0 {
+			e[i]--
+		}
+	}
+}
+
+// Drop leaves a power-up behind where a destroyed asteroid was, sometimes
+func (w *World) Drop(a *Asteroid) {
+	if w.Rand.Float64() >= PowerUpChance {
+		return
+	}
+	p := w.Pool.PowerUp()
+	*p.Object = Object{Center: a.Center, Last: a.Center, Radius: w.Sizes.PowerUp}
+	*p = PowerUp{
+		Object:   p.Object,
+		Power:    Power(w.Rand.Intn(int(Powers))),
+		Angle:    a.Angle,
+		Distance: a.Distance,
+		Alive:    true,
+	}
+	w.PowerUps = append(w.PowerUps, p)
+}
+
+// Collect picks up a power-up and puts its Power into effect
+func (w *World) Collect(p *PowerUp) {
+	p.Alive = false
+	w.Emit(PowerUpCollected)
+	if p.Power == SmartBomb {
+		w.SmartBomb()
+		return
+	}
+	w.Effects[p.Power] = Ticks(time.Duration(PowerUpDuration * float64(time.Second)))
+}
+
+// SmartBomb destroys every asteroid in play, just like shooting them all
+func (w *World) SmartBomb() {
+	hits := 0
+	for _, v := range w.Asteroids {
+		if v.Hittable() {
+			w.destroy(v)
+			hits++
+		}
+	}
+	if hits > 0 {
+		w.Emit(AsteroidShot)
+		w.Scheduler.After(Ticks(time.Millisecond*100), w.shotExploded)
+		w.AddScore(PointsShot * hits)
+	}
+	w.addFragments()
+}
@@ -0,0 +1,167 @@
+package sim
+
+import (
+	"image"
+	"testing"
+	"time"
+)
+
+// addPowerUp puts a power-up into the world at the given distance from the
+// Earth, at angle zero
+func addPowerUp(w *World, p Power, distance float64) *PowerUp {
+	pu := &PowerUp{Object: NewObject(w.Sizes.PowerUp), Power: p, Distance: distance, Alive: true}
+	pu.Update(w)
+	pu.Distance += PowerUpSpeed // undo the drift
+	w.PowerUps = append(w.PowerUps, pu)
+	return pu
+}
+
+func TestDrop(t *testing.T) {
+	defer func(c float64) { PowerUpChance = c }(PowerUpChance)
+	PowerUpChance = 1
+
+	w := newTestWorld(t)
+	target := w.Asteroids[0]
+	w.Update(Input{})
+	w.Update(Input{Cursor: target.Center.Point(), Clicked: true})
+	if len(w.PowerUps) != 1 {
+		t.Fatalf("%d power-ups after shooting an asteroid, want 1", len(w.PowerUps))
+	}
+	if p := w.PowerUps[0]; p.Angle != target.Angle || p.Distance > target.Distance {
+		t.Errorf("power-up dropped at %v, %v, asteroid was at %v, %v", p.Angle, p.Distance, target.Angle, target.Distance)
+	}
+}
+
+func TestCollectByShooting(t *testing.T) {
+	w := newTestWorld(t)
+	p := addPowerUp(w, WideLaser, 400)
+	w.Update(Input{Cursor: p.Center.Point(), Clicked: true})
+	if !hasEvent(w, PowerUpCollected) || p.Alive {
+		t.Fatalf("shooting a power-up didn't collect it")
+	}
+	if w.Crosshair.CoolingDown {
+		t.Errorf("shooting a power-up counted as a miss")
+	}
+	if !w.Effects.Active(WideLaser) {
+		t.Errorf("wide laser not in effect")
+	}
+	if w.Crosshair.Width(w) != LaserWidth*WideLaserRatio {
+		t.Errorf("wide laser is %v wide", w.Crosshair.Width(w))
+	}
+	w.Update(Input{})
+	if len(w.PowerUps) != 0 {
+		t.Errorf("collected power-up still around")
+	}
+}
+
+func TestCollectByMoon(t *testing.T) {
+	w := newTestWorld(t)
+	w.Moon.Phase = RotationSpeed / MoonOrbitRatio // so the Moon is at angle zero after the tick
+	addPowerUp(w, Shield, w.Moon.Radius*MoonOrbitDistance)
+	w.Update(Input{})
+	if !w.Effects.Active(Shield) {
+		t.Errorf("the Moon didn't collect the power-up")
+	}
+}
+
+func TestPowerUpBurnsUp(t *testing.T) {
+	w := newTestWorld(t)
+	addPowerUp(w, Shield, PowerUpSpeed)
+	w.Update(Input{})
+	if len(w.PowerUps) != 0 {
+		t.Errorf("power-up didn't burn up on reaching the Earth")
+	}
+}
+
+func TestEffectsRunOut(t *testing.T) {
+	w := newTestWorld(t)
+	w.Collect(&PowerUp{Power: SlowMotion, Alive: true})
+	ticks := w.Effects[SlowMotion]
+	if ticks != Ticks(time.Second*10) {
+		t.Errorf("slow motion lasts %d ticks", ticks)
+	}
+	for i := 0; i < ticks; i++ {
+		w.Update(Input{})
+	}
+	if w.Effects.Active(SlowMotion) {
+		t.Errorf("slow motion still going after %d ticks", ticks)
+	}
+}
+
+func TestShield(t *testing.T) {
+	w := newTestWorld(t)
+	w.Effects[Shield] = 100
+	w.Asteroids[0].Place(0, 0, w.Earth.Radius)
+	count := w.Count
+	for i := 0; i < ExplosionFrames+2; i++ {
+		w.Update(Input{})
+	}
+	if w.Earth.Impacted || w.State == GameOver {
+		t.Fatalf("asteroid got through the shield")
+	}
+	if w.Count != count-1 {
+		t.Errorf("count = %d, want %d", w.Count, count-1)
+	}
+}
+
+func TestCooldownPowers(t *testing.T) {
+	w := newTestWorld(t)
+	w.Effects[NoCooldown] = 100
+	w.Update(Input{Cursor: image.Pt(-1000, -1000), Clicked: true})
+	if w.Crosshair.CoolingDown {
+		t.Errorf("cooling down with no cooldown")
+	}
+
+	w.Effects[NoCooldown] = 0
+	w.Effects[RapidFire] = 100
+	w.Update(Input{Cursor: image.Pt(-1000, -1000), Clicked: true})
+	for i := 0; i < Ticks(time.Second/2); i++ {
+		w.Update(Input{})
+	}
+	if w.Crosshair.CoolingDown {
+		t.Errorf("rapid fire still cooling down after half a second")
+	}
+}
+
+func TestSlowMotion(t *testing.T) {
+	w := newTestWorld(t)
+	normal := NewAsteroid(AsteroidTypeByName("Normal"), w.Sizes, 0, 300)
+	slow := NewAsteroid(AsteroidTypeByName("Normal"), w.Sizes, 0, 300)
+	normal.Update(w)
+	w.Effects[SlowMotion] = 1
+	slow.Update(w)
+	if slow.Distance <= normal.Distance {
+		t.Errorf("slow asteroid at %v isn't behind normal one at %v", slow.Distance, normal.Distance)
+	}
+}
+
+func TestSmartBomb(t *testing.T) {
+	w := newTestWorld(t)
+	count := w.Count
+	w.Collect(&PowerUp{Power: SmartBomb, Alive: true})
+	if w.Count != count-1 || !w.Asteroids[0].Explosion.Exploding {
+		t.Errorf("smart bomb destroyed %d asteroids, want the 1 released", count-w.Count)
+	}
+	if w.Score != PointsShot || w.Effects.Active(SmartBomb) {
+		t.Errorf("score %d after smart bomb, want %d", w.Score, PointsShot)
+	}
+}
+
+func TestSmartBombSplits(t *testing.T) {
+	defer func(c float64) { PowerUpChance = c }(PowerUpChance)
+	PowerUpChance = 1
+
+	w := newTestWorld(t)
+	target := NewAsteroid(AsteroidTypeByName("Splitting"), w.Sizes, 0, 300)
+	w.Asteroids = Asteroids{target}
+	w.Count = 1
+	w.Update(Input{})
+
+	w.Collect(&PowerUp{Power: SmartBomb, Alive: true})
+	if len(w.Asteroids) != 1+target.Type.Fragments || w.Count != target.Type.Fragments {
+		t.Errorf("smart bomb split it into %d asteroids, count %d", len(w.Asteroids)-1, w.Count)
+	}
+	if len(w.PowerUps) != 1 {
+		t.Errorf("%d power-ups after a smart bomb, want 1", len(w.PowerUps))
+	}
+}
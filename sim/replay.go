@@ -0,0 +1,472 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+package sim
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Replay is a recording of a game, the seed, the settings and every tick's
+// input are all that's needed to play it again exactly as long as the rest of
+// the configuration is the same as when it was recorded
+type Replay struct {
+	Seed     int64
+	Gameplay *Gameplay // nil for replays older than version 6, which don't have it
+	Inputs   []Input
+}
+
+// Gameplay is the settings a game was recorded with that change what happens
+// for the same input
+type Gameplay struct {
+	CoOp          bool
+	MoonSteering  bool
+	MoonKnockback bool
+	Waves         Waves // nil if they were made up as the game went
+}
+
+const (
+	replayMagic   = "LDRP"
+	replayVersion = 6
+)
+
+// maxTypeName is the longest asteroid type name a replay can have, so a broken
+// one can't make us allocate gigabytes
+const maxTypeName = 64
+
+// Bits in the settings byte of the header
+const (
+	settingCoOp byte = 1 << iota
+	settingMoonSteering
+	settingMoonKnockback
+)
+
+// Bits in the flags byte of an encoded Input
+const (
+	flagClicked byte = 1 << iota
+	flagPause
+	flagUp
+	flagDown
+	flagConfirm
+	flagChoice // followed by the choice
+	flagThrust
+	flagBrake
+)
+
+// Bits in the second flags byte, which versions before 4 don't have
+const (
+	flagTurret  byte = 1 << iota // followed by the turret
+	flagPlayer2                  // followed by the change in their cursor, since version 5
+	flagClicked2
+)
+
+// Record adds one tick's input to the end of the Replay
+func (r *Replay) Record(in Input) {
+	r.Inputs = append(r.Inputs, in)
+}
+
+// Play runs every recorded tick through w, which should be a new World made
+// with the Replay's seed
+func (r *Replay) Play(w *World) {
+	for _, in := range r.Inputs {
+		w.Update(in)
+	}
+}
+
+// Save writes the Replay in a compact binary format, runs of identical input
+// are stored only once and cursor positions as the change from the last one
+func (r *Replay) Save(w io.Writer) error {
+	buf := []byte(replayMagic)
+	buf = append(buf, replayVersion)
+	buf = binary.AppendVarint(buf, r.Seed)
+	buf = appendGameplay(buf, r.Gameplay)
+
+	var last Input
+	for i := 0; i < len(r.Inputs); {
+		in := r.Inputs[i]
+		run := 1
+		for i+run < len(r.Inputs) && r.Inputs[i+run] == in {
+			run++
+		}
+		buf = binary.AppendUvarint(buf, uint64(run))
+		buf = binary.AppendVarint(buf, int64(in.Cursor.X-last.Cursor.X))
+		buf = binary.AppendVarint(buf, int64(in.Cursor.Y-last.Cursor.Y))
+		buf = appendInput(buf, in, last)
+		last = in
+		i += run
+	}
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// LoadReplay reads a Replay written by Save
+func LoadReplay(r io.Reader) (*Replay, error) {
+	br := bufio.NewReader(r)
+
+	header := make([]byte, len(replayMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("reading replay header: %w", err)
+	}
+	if string(header[:len(replayMagic)]) != replayMagic {
+		return nil, errors.New("not a replay file")
+	}
+	// Older replays are the same but only click before version 2, never steer
+	// the Moon before version 3, pick turrets with the menu choice before
+	// version 4, have no second player before version 5 and have no Gameplay
+	// before version 6
+	version := header[len(replayMagic)]
+	if version < 1 || version > replayVersion {
+		return nil, fmt.Errorf("unsupported replay version %d", version)
+	}
+
+	seed, err := binary.ReadVarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("reading replay seed: %w", err)
+	}
+	replay := &Replay{Seed: seed}
+	if version >= 6 {
+		if replay.Gameplay, err = readGameplay(br); err != nil {
+			return nil, fmt.Errorf("reading replay gameplay: %w", err)
+		}
+	}
+
+	var last Input
+	for {
+		run, err := binary.ReadUvarint(br)
+		if err == io.EOF {
+			return replay, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("reading replay tick %d: %w", len(replay.Inputs), err)
+		}
+		in, err := readInput(br, last, version)
+		if err != nil {
+			return nil, fmt.Errorf("reading replay tick %d: %w", len(replay.Inputs), err)
+		}
+		for ; run > 0; run-- {
+			replay.Record(in)
+		}
+		last = in
+	}
+}
+
+// appendGameplay encodes the settings flags then every wave, with no Gameplay
+// written as the defaults
+func appendGameplay(buf []byte, s *Gameplay) []byte {
+	if s == nil {
+		s = &Gameplay{}
+	}
+	var flags byte
+	for _, v := range []struct {
+		set  bool
+		flag byte
+	}{
+		{s.CoOp, settingCoOp},
+		{s.MoonSteering, settingMoonSteering},
+		{s.MoonKnockback, settingMoonKnockback},
+	} {
+		if v.set {
+			flags |= v.flag
+		}
+	}
+	buf = append(buf, flags)
+
+	buf = binary.AppendUvarint(buf, uint64(len(s.Waves)))
+	for _, spec := range s.Waves {
+		buf = binary.AppendUvarint(buf, uint64(spec.Count))
+		buf = binary.AppendUvarint(buf, uint64(len(spec.Types)))
+		for _, tw := range spec.Types {
+			buf = binary.AppendUvarint(buf, uint64(len(tw.Type.Name)))
+			buf = append(buf, tw.Type.Name...)
+			buf = binary.AppendUvarint(buf, uint64(tw.Weight))
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(spec.Sectors)))
+		for _, sector := range spec.Sectors {
+			buf = appendFloat(buf, sector.From)
+			buf = appendFloat(buf, sector.To)
+		}
+		buf = appendFloat(buf, spec.Spread)
+		buf = appendFloat(buf, spec.Speed)
+	}
+	return buf
+}
+
+// readGameplay decodes a Gameplay encoded by appendGameplay
+func readGameplay(br *bufio.Reader) (*Gameplay, error) {
+	flags, err := br.ReadByte()
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	s := &Gameplay{
+		CoOp:          flags&settingCoOp != 0,
+		MoonSteering:  flags&settingMoonSteering != 0,
+		MoonKnockback: flags&settingMoonKnockback != 0,
+	}
+
+	// Every error from here on is in the middle of something
+	waves, err := binary.ReadUvarint(br)
+	for i := uint64(0); err == nil && i < waves; i++ {
+		var spec WaveSpec
+		spec, err = readWave(br)
+		s.Waves = append(s.Waves, spec)
+	}
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	return s, nil
+}
+
+// readWave decodes a single WaveSpec written by appendGameplay, checking it
+// the same way LoadWaves does
+func readWave(br *bufio.Reader) (WaveSpec, error) {
+	var spec WaveSpec
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return spec, err
+	}
+	if count < 1 || count > maxCount {
+		return spec, fmt.Errorf("wave of %d asteroids", count)
+	}
+	spec.Count = int(count)
+
+	types, err := binary.ReadUvarint(br)
+	if err != nil {
+		return spec, err
+	}
+	for ; types > 0; types-- {
+		length, err := binary.ReadUvarint(br)
+		if err != nil {
+			return spec, err
+		}
+		if length > maxTypeName {
+			return spec, fmt.Errorf("asteroid type name %d bytes long", length)
+		}
+		name := make([]byte, length)
+		if _, err := io.ReadFull(br, name); err != nil {
+			return spec, err
+		}
+		weight, err := binary.ReadUvarint(br)
+		if err != nil {
+			return spec, err
+		}
+		if weight < 1 || weight > maxWeight {
+			return spec, fmt.Errorf("asteroid type weight %d", weight)
+		}
+		kind := AsteroidTypeByName(string(name))
+		if kind == nil {
+			return spec, fmt.Errorf("unknown asteroid type %q", name)
+		}
+		spec.Types = append(spec.Types, TypeWeight{Type: kind, Weight: int(weight)})
+	}
+
+	sectors, err := binary.ReadUvarint(br)
+	if err != nil {
+		return spec, err
+	}
+	for ; sectors > 0; sectors-- {
+		var sector Sector
+		if sector.From, err = readFloat(br); err != nil {
+			return spec, err
+		}
+		if sector.To, err = readFloat(br); err != nil {
+			return spec, err
+		}
+		if !(0 <= sector.From && sector.From < sector.To && sector.To <= 2*math.Pi) {
+			return spec, fmt.Errorf("sector from %v to %v", sector.From, sector.To)
+		}
+		spec.Sectors = append(spec.Sectors, sector)
+	}
+
+	if spec.Spread, err = readFloat(br); err != nil {
+		return spec, err
+	}
+	if !(spec.Spread >= 0 || spec.Spread == -1) {
+		return spec, fmt.Errorf("asteroids spread over %v seconds", spec.Spread)
+	}
+	if spec.Speed, err = readFloat(br); err != nil {
+		return spec, err
+	}
+	if !(spec.Speed > 0) {
+		return spec, fmt.Errorf("asteroid speed %v", spec.Speed)
+	}
+	return spec, nil
+}
+
+// appendFloat encodes f exactly, in 8 bytes
+func appendFloat(buf []byte, f float64) []byte {
+	return binary.LittleEndian.AppendUint64(buf, math.Float64bits(f))
+}
+
+// readFloat decodes a float64 written by appendFloat
+func readFloat(br *bufio.Reader) (float64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(br, b[:]); err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b[:])), nil
+}
+
+// MarshalBinary encodes a single Input the same way a replay does, for
+// sending it somewhere
+func (in Input) MarshalBinary() ([]byte, error) {
+	buf := binary.AppendVarint(nil, int64(in.Cursor.X))
+	buf = binary.AppendVarint(buf, int64(in.Cursor.Y))
+	return appendInput(buf, in, Input{}), nil
+}
+
+// UnmarshalBinary decodes an Input encoded by MarshalBinary
+func (in *Input) UnmarshalBinary(data []byte) error {
+	decoded, err := readInput(bufio.NewReader(bytes.NewReader(data)), Input{}, replayVersion)
+	if err != nil {
+		return err
+	}
+	*in = decoded
+	return nil
+}
+
+// MarshalBinary encodes Gameplay the same way a replay does, for sending it
+// somewhere
+func (g *Gameplay) MarshalBinary() ([]byte, error) {
+	return appendGameplay(nil, g), nil
+}
+
+// UnmarshalBinary decodes Gameplay encoded by MarshalBinary
+func (g *Gameplay) UnmarshalBinary(data []byte) error {
+	decoded, err := readGameplay(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		return err
+	}
+	*g = *decoded
+	return nil
+}
+
+// appendInput encodes the buttons and choices of an Input, and the second
+// player's cursor relative to the last Input, the first player's cursor is
+// encoded separately
+func appendInput(buf []byte, in, last Input) []byte {
+	var flags byte
+	for _, v := range []struct {
+		set  bool
+		flag byte
+	}{
+		{in.Clicked, flagClicked},
+		{in.Pause, flagPause},
+		{in.Up, flagUp},
+		{in.Down, flagDown},
+		{in.Confirm, flagConfirm},
+		{in.Choice != 0, flagChoice},
+		{in.Thrust > 0, flagThrust},
+		{in.Thrust < 0, flagBrake},
+	} {
+		if v.set {
+			flags |= v.flag
+		}
+	}
+	buf = append(buf, flags)
+	if in.Choice != 0 {
+		buf = binary.AppendUvarint(buf, uint64(in.Choice))
+	}
+
+	var flags2 byte
+	if in.Turret != 0 {
+		flags2 |= flagTurret
+	}
+	if in.Player2 != (Aim{}) {
+		flags2 |= flagPlayer2
+	}
+	if in.Player2.Clicked {
+		flags2 |= flagClicked2
+	}
+	buf = append(buf, flags2)
+	if in.Turret != 0 {
+		buf = binary.AppendUvarint(buf, uint64(in.Turret))
+	}
+	if flags2&flagPlayer2 != 0 {
+		buf = binary.AppendVarint(buf, int64(in.Player2.Cursor.X-last.Player2.Cursor.X))
+		buf = binary.AppendVarint(buf, int64(in.Player2.Cursor.Y-last.Player2.Cursor.Y))
+	}
+	return buf
+}
+
+// readInput decodes a single Input stored relative to the last one, in a
+// replay of the given version
+func readInput(br *bufio.Reader, last Input, version byte) (Input, error) {
+	dx, err := binary.ReadVarint(br)
+	if err != nil {
+		return last, unexpected(err)
+	}
+	dy, err := binary.ReadVarint(br)
+	if err != nil {
+		return last, unexpected(err)
+	}
+	flags, err := br.ReadByte()
+	if err != nil {
+		return last, unexpected(err)
+	}
+
+	in := Input{}
+	in.Cursor.X = last.Cursor.X + int(dx)
+	in.Cursor.Y = last.Cursor.Y + int(dy)
+	in.Clicked = flags&flagClicked != 0
+	in.Pause = flags&flagPause != 0
+	in.Up = flags&flagUp != 0
+	in.Down = flags&flagDown != 0
+	in.Confirm = flags&flagConfirm != 0
+	if flags&flagThrust != 0 {
+		in.Thrust = 1
+	} else if flags&flagBrake != 0 {
+		in.Thrust = -1
+	}
+	if flags&flagChoice != 0 {
+		choice, err := binary.ReadUvarint(br)
+		if err != nil {
+			return last, unexpected(err)
+		}
+		in.Choice = int(choice)
+	}
+	if version < 4 {
+		in.Turret = in.Choice
+		return in, nil
+	}
+
+	flags2, err := br.ReadByte()
+	if err != nil {
+		return last, unexpected(err)
+	}
+	if flags2&flagTurret != 0 {
+		turret, err := binary.ReadUvarint(br)
+		if err != nil {
+			return last, unexpected(err)
+		}
+		in.Turret = int(turret)
+	}
+	if flags2&flagPlayer2 != 0 {
+		dx, err := binary.ReadVarint(br)
+		if err != nil {
+			return last, unexpected(err)
+		}
+		dy, err := binary.ReadVarint(br)
+		if err != nil {
+			return last, unexpected(err)
+		}
+		in.Player2.Cursor.X = last.Player2.Cursor.X + int(dx)
+		in.Player2.Cursor.Y = last.Player2.Cursor.Y + int(dy)
+		in.Player2.Clicked = flags2&flagClicked2 != 0
+	}
+	return in, nil
+}
+
+// unexpected turns an EOF in the middle of something into an unexpected one
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,179 @@
+package sim
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// scriptedReplay plays a short game clicking around the Moon's orbit
+func scriptedReplay(seed int64) *Replay {
+	r := &Replay{Seed: seed}
+	r.Record(Input{Clicked: true})
+	for i := 0; i < 1200; i++ {
+		in := Input{Cursor: image.Pt(200+i%900, 150+(i*7)%700)}
+		in.Clicked = i%45 == 0
+		in.Pause = i == 300 || i == 330
+		in.Down = i == 310
+		in.Choice = map[int]int{320: 3, 321: 4}[i]
+		in.Turret = map[int]int{800: 2, 900: 2}[i]
+		in.Thrust = map[int]int{400: 1, 401: 1, 500: -1}[i]
+		if i >= 600 && i < 700 {
+			in.Player2 = Aim{Cursor: image.Pt(1000-i, 100+i%50), Clicked: i%30 == 0}
+		}
+		r.Record(in)
+	}
+	return r
+}
+
+func TestReplaySaveLoad(t *testing.T) {
+	waves, err := LoadWaves(strings.NewReader(testWaves))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := scriptedReplay(99)
+	want.Gameplay = &Gameplay{CoOp: true, MoonKnockback: true, Waves: waves}
+	var buf bytes.Buffer
+	if err := want.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadReplay(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded replay differs from the saved one")
+	}
+}
+
+func TestReplayCompact(t *testing.T) {
+	r := &Replay{Seed: 1}
+	for i := 0; i < 600; i++ {
+		r.Record(Input{Cursor: image.Pt(640, 480)})
+	}
+	var buf bytes.Buffer
+	if err := r.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > 16 {
+		t.Errorf("10s of still input took %d bytes", buf.Len())
+	}
+}
+
+func TestLoadReplayErrors(t *testing.T) {
+	if _, err := LoadReplay(bytes.NewBufferString("nope!")); err == nil {
+		t.Errorf("loaded a replay with no magic")
+	}
+
+	var buf bytes.Buffer
+	if err := scriptedReplay(1).Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	truncated := buf.Bytes()[:buf.Len()-1]
+	if _, err := LoadReplay(bytes.NewReader(truncated)); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated replay gave %v, want unexpected EOF", err)
+	}
+
+	for _, spec := range []WaveSpec{
+		{Count: 0, Spread: -1, Speed: 1},
+		{Count: maxCount + 1, Spread: -1, Speed: 1},
+		{Count: 1, Spread: -1, Speed: 1, Types: []TypeWeight{{AsteroidTypeByName("Small"), 0}}},
+		{Count: 1, Spread: -1, Speed: 1, Sectors: []Sector{{From: 1, To: 1}}},
+		{Count: 1, Spread: -1, Speed: 1, Sectors: []Sector{{From: 0, To: 7}}},
+		{Count: 1, Spread: -2, Speed: 1},
+		{Count: 1, Spread: math.NaN(), Speed: 1},
+		{Count: 1, Spread: -1, Speed: 0},
+		{Count: 1, Spread: -1, Speed: math.NaN()},
+	} {
+		buf.Reset()
+		r := &Replay{Seed: 1, Gameplay: &Gameplay{Waves: Waves{spec}}}
+		if err := r.Save(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadReplay(&buf); err == nil {
+			t.Errorf("loaded a replay with wave %+v", spec)
+		}
+	}
+}
+
+func TestLoadOldReplay(t *testing.T) {
+	// Version 3, seed 1, one tick braking at (2,3) then one choosing 2, which
+	// picked a turret as well back then
+	old := []byte{'L', 'D', 'R', 'P', 3, 2, 1, 4, 6, flagBrake, 1, 0, 0, flagChoice, 2}
+	r, err := LoadReplay(bytes.NewReader(old))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Input{
+		{Cursor: image.Pt(2, 3), Thrust: -1},
+		{Cursor: image.Pt(2, 3), Choice: 2, Turret: 2},
+	}
+	if !reflect.DeepEqual(r.Inputs, want) || r.Gameplay != nil {
+		t.Errorf("loaded %+v with gameplay %+v, want %+v", r.Inputs, r.Gameplay, want)
+	}
+
+	// Version 1, seed 1, two ticks clicking at (2,3)
+	old = []byte{'L', 'D', 'R', 'P', 1, 2, 2, 4, 6, flagClicked}
+	if r, err = LoadReplay(bytes.NewReader(old)); err != nil {
+		t.Fatal(err)
+	}
+	click := Input{Cursor: image.Pt(2, 3), Clicked: true}
+	if !reflect.DeepEqual(r.Inputs, []Input{click, click}) {
+		t.Errorf("loaded %+v from version 1", r.Inputs)
+	}
+}
+
+func TestReplayDeterministic(t *testing.T) {
+	recorded := scriptedReplay(1234)
+	live := NewWorld(1280, 960, DefaultSizes, recorded.Seed)
+	for _, in := range recorded.Inputs {
+		live.Update(in)
+	}
+
+	var buf bytes.Buffer
+	if err := recorded.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadReplay(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed := NewWorld(1280, 960, DefaultSizes, loaded.Seed)
+	loaded.Play(replayed)
+
+	if replayed.Count != live.Count || replayed.Wave != live.Wave ||
+		replayed.State != live.State || replayed.Score != live.Score {
+		t.Errorf("replay ended on wave %d with %d left, live game on wave %d with %d left",
+			replayed.Wave, replayed.Count, live.Wave, live.Count)
+	}
+}
+
+func TestInputMarshal(t *testing.T) {
+	want := Input{
+		Cursor:  image.Pt(-3, 700),
+		Clicked: true,
+		Choice:  2,
+		Turret:  1,
+		Thrust:  -1,
+		Player2: Aim{Cursor: image.Pt(5, 6), Clicked: true},
+	}
+	data, err := want.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Input
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("unmarshalled %+v, want %+v", got, want)
+	}
+	if err := got.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Errorf("unmarshalled a truncated input")
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+package sim
+
+import "time"
+
+// TPS is how many ticks the game world advances in a second of play
+const TPS = 60
+
+// Ticks converts a duration of play to a number of ticks
+func Ticks(d time.Duration) int {
+	return int(d * TPS / time.Second)
+}
+
+// A Scheduler runs delayed actions in step with the game world instead of the
+// wall clock, so that nothing happens while the game isn't being updated
+type Scheduler struct {
+	Now    int // ticks since the Scheduler was made
+	timers []timer
+}
+
+type timer struct {
+	at int
+	fn func()
+}
+
+// After schedules fn to run once the given number of ticks have passed, a
+// delay of less than one tick runs it on the next tick
+func (s *Scheduler) After(ticks int, fn func()) {
+	if ticks < 1 {
+		ticks = 1
+	}
+	t := timer{at: s.Now + ticks, fn: fn}
+
+	// Keep timers in order, with timers due on the same tick in the order
+	// they were scheduled
+	i := len(s.timers)
+	for i > 0 && s.timers[i-1].at > t.at {
+		i--
+	}
+	s.timers = append(s.timers, timer{})
+	copy(s.timers[i+1:], s.timers[i:])
+	s.timers[i] = t
+}
+
+// Pending returns how many actions are still waiting to run
+func (s *Scheduler) Pending() int {
+	return len(s.timers)
+}
+
+// Clear cancels everything that's waiting to run
+func (s *Scheduler) Clear() {
+	clear(s.timers)
+	s.timers = s.timers[:0]
+}
+
+// Update advances the Scheduler by one tick and runs everything that is due,
+// timers are shuffled down rather than sliced off the front so that their
+// space gets reused
+func (s *Scheduler) Update() {
+	s.Now++
+	for len(s.timers) > 0 && s.timers[0].at <= s.Now {
+		t := s.timers[0]
+		last := len(s.timers) - 1
+		copy(s.timers, s.timers[1:])
+		s.timers[last] = timer{}
+		s.timers = s.timers[:last]
+		t.fn()
+	}
+}
@@ -0,0 +1,41 @@
+package sim
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTicks(t *testing.T) {
+	if got := Ticks(time.Second); got != TPS {
+		t.Errorf("Ticks(1s) = %d, want %d", got, TPS)
+	}
+	if got := Ticks(time.Millisecond * 100); got != TPS/10 {
+		t.Errorf("Ticks(100ms) = %d, want %d", got, TPS/10)
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	s := &Scheduler{}
+	var ran []string
+	s.After(3, func() { ran = append(ran, "c") })
+	s.After(1, func() { ran = append(ran, "a") })
+	s.After(3, func() { ran = append(ran, "d") })
+	s.After(0, func() {
+		ran = append(ran, "b")
+		s.After(0, func() { ran = append(ran, "e") })
+	})
+
+	s.Update()
+	if want := []string{"a", "b"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("after 1 tick ran %v, want %v", ran, want)
+	}
+	s.Update()
+	s.Update()
+	if want := []string{"a", "b", "e", "c", "d"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("after 3 ticks ran %v, want %v", ran, want)
+	}
+	if s.Pending() != 0 {
+		t.Errorf("%d timers still pending", s.Pending())
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+package sim
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+var (
+	PointsShot    int = 100 // for each asteroid shot by the laser
+	PointsRammed  int = 50  // for each asteroid rammed by the Moon
+	PointsMissed  int = 50  // taken away for each shot that misses
+	MaxHighScores int = 10
+)
+
+// ShotPoints is how many points a single shot hitting this many asteroids is
+// worth, each asteroid hit in the same shot multiplies the points of all of
+// them
+func ShotPoints(hits int) int {
+	return PointsShot * hits * hits
+}
+
+// AddScore adds points to the score, which never goes below zero
+func (w *World) AddScore(points int) {
+	w.Score += points
+	if w.Score < 0 {
+		w.Score = 0
+	}
+}
+
+// A HighScore is the result of a game that went well
+type HighScore struct {
+	Score int
+	Wave  int
+}
+
+// HighScores are the best games played, best first
+type HighScores []HighScore
+
+// Add puts a new score in its place in the table, which is kept to
+// MaxHighScores long, and reports where it went or -1 if it wasn't good enough
+func (hs HighScores) Add(s HighScore) (HighScores, int) {
+	rank := sort.Search(len(hs), func(i int) bool {
+		return hs[i].Score < s.Score
+	})
+	if rank >= MaxHighScores || s.Score <= 0 {
+		return hs, -1
+	}
+	hs = append(hs, HighScore{})
+	copy(hs[rank+1:], hs[rank:])
+	hs[rank] = s
+	if len(hs) > MaxHighScores {
+		hs = hs[:MaxHighScores]
+	}
+	return hs, rank
+}
+
+// LoadHighScores reads a high score table written by Save
+func LoadHighScores(r io.Reader) (HighScores, error) {
+	var hs HighScores
+	if err := json.NewDecoder(r).Decode(&hs); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(hs, func(i, j int) bool {
+		return hs[i].Score > hs[j].Score
+	})
+	if len(hs) > MaxHighScores {
+		hs = hs[:MaxHighScores]
+	}
+	return hs, nil
+}
+
+// Save writes the high score table as JSON
+func (hs HighScores) Save(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(hs)
+}
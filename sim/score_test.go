@@ -0,0 +1,64 @@
+package sim
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestShotPoints(t *testing.T) {
+	for hits, want := range []int{0, PointsShot, PointsShot * 4, PointsShot * 9} {
+		if got := ShotPoints(hits); got != want {
+			t.Errorf("ShotPoints(%d) = %d, want %d", hits, got, want)
+		}
+	}
+}
+
+func TestAddScore(t *testing.T) {
+	w := NewWorld(1280, 960, DefaultSizes, 1)
+	w.AddScore(30)
+	w.AddScore(-50)
+	if w.Score != 0 {
+		t.Errorf("score = %d, want 0", w.Score)
+	}
+}
+
+func TestHighScoresAdd(t *testing.T) {
+	var hs HighScores
+	for i := 1; i <= MaxHighScores; i++ {
+		hs, _ = hs.Add(HighScore{Score: i * 100, Wave: i})
+	}
+	if hs[0].Score != MaxHighScores*100 || hs[len(hs)-1].Score != 100 {
+		t.Fatalf("high scores out of order: %v", hs)
+	}
+
+	hs, rank := hs.Add(HighScore{Score: 250, Wave: 3})
+	if rank != MaxHighScores-2 {
+		t.Errorf("250 ranked %d, want %d", rank, MaxHighScores-2)
+	}
+	if len(hs) != MaxHighScores || hs[len(hs)-1].Score != 200 {
+		t.Errorf("lowest score not dropped: %v", hs)
+	}
+
+	if _, rank := hs.Add(HighScore{Score: 50}); rank != -1 {
+		t.Errorf("50 ranked %d in a full table", rank)
+	}
+	if _, rank := (HighScores{}).Add(HighScore{Score: 0}); rank != -1 {
+		t.Errorf("zero ranked %d", rank)
+	}
+}
+
+func TestHighScoresSaveLoad(t *testing.T) {
+	want := HighScores{{Score: 900, Wave: 4}, {Score: 300, Wave: 2}}
+	var buf bytes.Buffer
+	if err := want.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadHighScores(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded %v, want %v", got, want)
+	}
+}
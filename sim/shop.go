@@ -0,0 +1,189 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+package sim
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	CreditsPerAsteroid int     = 1    // credits earned for each asteroid the player destroys
+	CooldownUpgrade    float64 = 0.7  // how much of the cooldown is left after each upgrade
+	ReachUpgrade       float64 = 0.25 // how much wider the laser hits with each upgrade
+	MultiShotRange     float64 = 4    // how many crosshairs away multi-shot can reach
+	OrbitUpgrade       float64 = 0.3  // how much faster the Moon orbits with each upgrade
+)
+
+// An Upgrade is something for the turret that can be bought between waves
+type Upgrade int
+
+const (
+	// FasterCooldown makes missed shots cool down faster
+	FasterCooldown Upgrade = iota
+	// BiggerCrosshair makes the laser hit a wider area
+	BiggerCrosshair
+	// MultiShot fires at another nearby asteroid with every shot
+	MultiShot
+	// PiercingLaser goes straight through armour and every asteroid in its way
+	PiercingLaser
+	// FasterOrbit makes the Moon go round faster
+	FasterOrbit
+	// Upgrades is how many kinds of Upgrade there are
+	Upgrades
+)
+
+var upgradeNames = [Upgrades]string{"FASTER COOLDOWN", "BIGGER CROSSHAIR", "MULTI-SHOT", "PIERCING LASER", "FASTER ORBIT"}
+
+func (u Upgrade) String() string {
+	return upgradeNames[u]
+}
+
+// UpgradeCosts are how many credits the first level of each Upgrade costs,
+// every level after that costs as much again
+var UpgradeCosts = [Upgrades]int{10, 10, 25, 40, 15}
+
+// UpgradeLevels are how many times each Upgrade can be bought
+var UpgradeLevels = [Upgrades]int{3, 3, 2, 1, 3}
+
+// ShopNextWave is the item in the shop menu that starts the next wave, it
+// comes after all the upgrades
+const ShopNextWave = int(Upgrades)
+
+// NewShopMenu makes the menu of upgrades shown between waves
+func NewShopMenu() *Menu {
+	return &Menu{Items: make([]string, ShopNextWave+1)}
+}
+
+// Level is how many times an Upgrade has been bought
+func (w *World) Level(u Upgrade) int {
+	switch u {
+	case FasterCooldown:
+		return w.Crosshair.CooldownLevel
+	case BiggerCrosshair:
+		return w.Crosshair.ReachLevel
+	case MultiShot:
+		return w.Moon.Shots
+	case PiercingLaser:
+		if w.Moon.Piercing {
+			return 1
+		}
+	case FasterOrbit:
+		return w.Moon.OrbitLevel
+	}
+	return 0
+}
+
+// Cost is how many credits the next level of an Upgrade costs, or -1 if it
+// can't be upgraded any more
+func (w *World) Cost(u Upgrade) int {
+	level := w.Level(u)
+	if level >= UpgradeLevels[u] {
+		return -1
+	}
+	return UpgradeCosts[u] * (level + 1)
+}
+
+// Buy spends credits on the next level of an Upgrade, it reports false if
+// there aren't enough credits or it can't be upgraded any more
+func (w *World) Buy(u Upgrade) bool {
+	cost := w.Cost(u)
+	if cost < 0 || cost > w.Credits {
+		return false
+	}
+	w.Credits -= cost
+	for _, c := range w.Crosshairs {
+		switch u {
+		case FasterCooldown:
+			c.CooldownLevel++
+		case BiggerCrosshair:
+			c.ReachLevel++
+		}
+	}
+	for _, m := range w.Moons {
+		switch u {
+		case MultiShot:
+			m.Shots++
+		case PiercingLaser:
+			m.Piercing = true
+		case FasterOrbit:
+			m.OrbitLevel++
+		}
+	}
+	log.Printf("bought %v for %d credits\n", u, cost)
+	return true
+}
+
+// CanAffordUpgrade reports whether there are enough credits to buy anything
+func (w *World) CanAffordUpgrade() bool {
+	for u := Upgrade(0); u < Upgrades; u++ {
+		if cost := w.Cost(u); cost >= 0 && cost <= w.Credits {
+			return true
+		}
+	}
+	return false
+}
+
+// ResetUpgrades takes away all the upgrades and credits for a new game
+func (w *World) ResetUpgrades() {
+	w.Credits = 0
+	for _, c := range w.Crosshairs {
+		c.CooldownLevel = 0
+		c.ReachLevel = 0
+	}
+	for _, m := range w.Moons {
+		m.Shots = 0
+		m.Piercing = false
+		m.OrbitLevel = 0
+	}
+}
+
+// labelShop updates the shop menu's items to show levels and prices, only
+// the ones whose level changed need new labels
+func (w *World) labelShop() {
+	for u := Upgrade(0); u < Upgrades; u++ {
+		if w.ShopMenu.Items[u] != "" && w.shopLevels[u] == w.Level(u) {
+			continue
+		}
+		w.shopLevels[u] = w.Level(u)
+		if cost := w.Cost(u); cost < 0 {
+			w.ShopMenu.Items[u] = fmt.Sprintf("%v: MAX", u)
+		} else {
+			w.ShopMenu.Items[u] = fmt.Sprintf("%v %d/%d: %d CR", u, w.Level(u)+1, UpgradeLevels[u], cost)
+		}
+	}
+	w.ShopMenu.Items[ShopNextWave] = "NEXT WAVE"
+}
+
+// openShop starts the break between waves
+func (w *World) openShop() {
+	w.Enter(WaveIntermission)
+	w.ShopMenu.Selected = ShopNextWave
+	w.labelShop()
+}
+
+// updateShop handles the player's input in the shop between waves, the next
+// wave starts when they're done shopping, or after a short break if they
+// can't afford anything
+func (w *World) updateShop(in Input) {
+	choice := w.ShopMenu.Update(in)
+	if choice >= 0 && choice < ShopNextWave {
+		w.Buy(Upgrade(choice))
+		w.labelShop()
+	}
+
+	done := choice == ShopNextWave
+	breather := w.InStateFor(time.Second*time.Duration(TimeBetweenWaves)) && !w.CanAffordUpgrade()
+	if done || breather {
+		w.HowMany *= WaveMultiplier
+		w.Restart()
+		w.Enter(Playing)
+
+		// Whatever closed the shop mustn't fire a shot in the new wave too
+		w.Input.Clicked = false
+		w.Input.Player2.Clicked = false
+	}
+}
@@ -0,0 +1,160 @@
+package sim
+
+import (
+	"image"
+	"testing"
+	"time"
+)
+
+// clearWave ends the current wave as if every asteroid had been shot
+func clearWave(t *testing.T, w *World) {
+	t.Helper()
+	for _, v := range w.Asteroids {
+		v.Alive = false
+	}
+	w.Update(Input{})
+	if w.State != WaveIntermission || w.Menu() != w.ShopMenu {
+		t.Fatalf("state %v after clearing the wave", w.State)
+	}
+}
+
+func TestShopBuy(t *testing.T) {
+	w := newTestWorld(t)
+	w.Credits = UpgradeCosts[FasterCooldown]*3 - 1
+	clearWave(t, w)
+
+	w.Update(Input{Choice: int(FasterCooldown) + 1})
+	if w.Crosshair.CooldownLevel != 1 || w.Credits != UpgradeCosts[FasterCooldown]*2-1 {
+		t.Errorf("level %d, %d credits left after buying", w.Crosshair.CooldownLevel, w.Credits)
+	}
+	w.Update(Input{Confirm: true})
+	if w.Crosshair.CooldownLevel != 1 {
+		t.Errorf("bought a level without enough credits")
+	}
+	if w.State != WaveIntermission {
+		t.Errorf("state %v, the shop shouldn't close while there's still shopping to do", w.State)
+	}
+
+	w.Update(Input{Choice: ShopNextWave + 1})
+	if w.State != Playing || w.Wave != 2 {
+		t.Errorf("state %v, wave %d after choosing the next wave", w.State, w.Wave)
+	}
+}
+
+func TestShopClosedByClick(t *testing.T) {
+	w := newTestWorld(t)
+	clearWave(t, w)
+	score := w.Score
+
+	// The click that picks NEXT WAVE is where the crosshair is too
+	w.Update(Input{Cursor: image.Pt(-1000, -1000), Clicked: true, Choice: ShopNextWave + 1})
+	if w.State != Playing {
+		t.Fatalf("state %v after clicking NEXT WAVE", w.State)
+	}
+	if hasEvent(w, LaserFired) || w.Crosshair.CoolingDown || w.Score != score {
+		t.Errorf("closing the shop fired a shot, score %d, cooling down %v", w.Score, w.Crosshair.CoolingDown)
+	}
+}
+
+func TestShopMaxLevel(t *testing.T) {
+	w := newTestWorld(t)
+	w.Credits = 1000
+	for i := 0; i < UpgradeLevels[PiercingLaser]; i++ {
+		if !w.Buy(PiercingLaser) {
+			t.Fatalf("couldn't buy level %d", i+1)
+		}
+	}
+	if w.Buy(PiercingLaser) || w.Cost(PiercingLaser) != -1 {
+		t.Errorf("bought more than the maximum level")
+	}
+}
+
+func TestShopClosesWhenBroke(t *testing.T) {
+	w := newTestWorld(t)
+	clearWave(t, w)
+	for i := 0; i < Ticks(time.Second*time.Duration(TimeBetweenWaves)); i++ {
+		w.Update(Input{})
+	}
+	if w.State != Playing {
+		t.Errorf("state %v, the next wave should start when there's nothing to buy", w.State)
+	}
+}
+
+func TestCredits(t *testing.T) {
+	w := newTestWorld(t)
+	target := w.Asteroids[0]
+	w.Update(Input{})
+	w.Update(Input{Cursor: target.Center.Point(), Clicked: true})
+	if w.Credits != CreditsPerAsteroid*w.Crosshair.Combo {
+		t.Errorf("%d credits after shooting %d asteroids", w.Credits, w.Crosshair.Combo)
+	}
+	w.StartOver()
+	if w.Credits != 0 {
+		t.Errorf("%d credits left in a new game", w.Credits)
+	}
+}
+
+func TestUpgradedCooldown(t *testing.T) {
+	w := newTestWorld(t)
+	w.Crosshair.CooldownLevel = 2
+	w.Update(Input{Cursor: image.Pt(-1000, -1000), Clicked: true})
+	for i := 0; i < Ticks(time.Second/2); i++ {
+		w.Update(Input{})
+	}
+	if w.Crosshair.CoolingDown {
+		t.Errorf("still cooling down after half a second with a faster cooldown")
+	}
+}
+
+func TestUpgradedReach(t *testing.T) {
+	w := newTestWorld(t)
+	w.Crosshair.ReachLevel = 2
+	if width := w.Crosshair.Width(w); width != LaserWidth*(1+ReachUpgrade*2) {
+		t.Errorf("laser %v wide with a bigger crosshair", width)
+	}
+}
+
+func TestMultiShot(t *testing.T) {
+	w := newTestWorld(t)
+	w.Moon.Shots = 1
+	near := NewAsteroid(AsteroidTypeByName("Normal"), w.Sizes, 0, 300)
+	nearer := NewAsteroid(AsteroidTypeByName("Normal"), w.Sizes, 0.2, 300)
+	w.AddAsteroids(Asteroids{near, nearer})
+	w.Asteroids[0].Alive = false
+	w.Update(Input{})
+
+	aim := nearer.Center.Add(Vec{0, w.Crosshair.Radius * 2}).Point() // a miss on its own
+	w.Update(Input{Cursor: aim, Clicked: true})
+	if w.Crosshair.Combo != 1 || !nearer.Explosion.Exploding || near.Explosion.Exploding {
+		t.Errorf("multi-shot hit %d asteroids, want the nearest one", w.Crosshair.Combo)
+	}
+	if len(w.Crosshair.Targets) != 1 || w.Crosshair.CoolingDown {
+		t.Errorf("multi-shot targets %v, cooling down %v", w.Crosshair.Targets, w.Crosshair.CoolingDown)
+	}
+}
+
+func TestPiercing(t *testing.T) {
+	w := newTestWorld(t)
+	w.Moon.Piercing = true
+	armoured := NewAsteroid(AsteroidTypeByName("Armoured"), w.Sizes, 0, 300)
+	w.AddAsteroids(Asteroids{armoured})
+	w.Update(Input{})
+	w.Update(Input{Cursor: armoured.Center.Point(), Clicked: true})
+	if !armoured.Explosion.Exploding {
+		t.Errorf("piercing laser didn't go through armour, %d hit points left", armoured.HitPoints)
+	}
+}
+
+func TestFasterOrbit(t *testing.T) {
+	w := newTestWorld(t)
+	before := w.Moon.Phase
+	w.Update(Input{})
+	normal := w.Moon.Phase - before
+
+	w.Moon.OrbitLevel = 1
+	before = w.Moon.Phase
+	w.Update(Input{})
+	if faster := w.Moon.Phase - before; faster >= normal {
+		t.Errorf("moon moved %v with a faster orbit, %v without", faster, normal)
+	}
+}
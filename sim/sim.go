@@ -0,0 +1,406 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+// Package sim is the game simulation of Lunar Defence, it advances the state
+// of the world one tick at a time from a snapshot of the player's input and
+// knows nothing about windows, sprites or sound so it can run headless.
+//
+// Everything in play is kept in the World's entity.Registry and updated,
+// collided with the moons or cleared away by which interfaces it implements,
+// so a new kind of thing only needs adding to the Registry. Positions are
+// Vecs, which aren't rounded to whole pixels, and each Object remembers where
+// it was when the tick started so frontends can draw it Between ticks.
+//
+// Collision checks look up the asteroids near what they're checking in a
+// Grid, which keeps big waves fast, run go test ./sim -run XXX -bench . to
+// compare with checking every asteroid. Asteroids and power-ups that are gone
+// go back in the Pool to be reused, so once a game has got going a tick
+// doesn't allocate, TestNoAllocations checks this and
+// go test ./sim -run XXX -bench Wave -benchmem measures it.
+package sim
+
+import (
+	"image"
+	"log"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/sinisterstuf/lunar-defence/entity"
+)
+
+var (
+	HowManyStart       int     = 5
+	EdgeOfScreenOffset float64 = 3
+	TimeBetweenWaves   int     = 2
+	WaveMultiplier     int     = 2
+	RotationSpeed      float64 = 0.02
+	MoonOrbitRatio     float64 = 2
+	MoonOrbitDistance  float64 = 5
+	AsteroidSpinRatio  float64 = 3
+	LaserWidth         float64 = 10
+)
+
+// Sizes are the radii of the bodies in the game, usually taken from the size
+// of the sprites used to draw them
+type Sizes struct {
+	Earth     float64
+	Moon      float64
+	Turret    float64
+	Asteroid  float64
+	Crosshair float64
+	Explosion float64
+	PowerUp   float64
+}
+
+// DefaultSizes match the sprites shipped with the game
+var DefaultSizes = Sizes{
+	Earth:     168,
+	Moon:      43.5,
+	Turret:    21,
+	Asteroid:  15.5,
+	Crosshair: 59,
+	Explosion: 42,
+	PowerUp:   20,
+}
+
+// Input is a snapshot of what the player is doing during a single tick
+type Input struct {
+	Cursor  image.Point
+	Clicked bool
+	Pause   bool // opens and closes the pause menu
+	Up      bool // moves up in menus
+	Down    bool // moves down in menus
+	Confirm bool // chooses the selected menu item
+	Choice  int  // a menu item chosen directly, counting from one
+	Turret  int  // a turret picked with a number key, counting from one
+	Thrust  int  // speeds the Moon up with 1 or slows it down with -1, when steering
+	Player2 Aim  // the second player's crosshair in co-op, or where they launch from in versus
+}
+
+// An Event is something that happened during a tick which the outside world
+// might want to react to, e.g. by playing a sound
+type Event int
+
+const (
+	// LaserFired is when the player shoots
+	LaserFired Event = iota
+	// AsteroidShot is when the laser hits an asteroid
+	AsteroidShot
+	// ShotExplosion is the bang of a shot asteroid, a moment after it's hit
+	ShotExplosion
+	// AsteroidDamaged is when the laser hits an asteroid that survives it
+	AsteroidDamaged
+	// AsteroidRammed is when the Moon crashes into an asteroid
+	AsteroidRammed
+	// AsteroidKnocked is when the Moon or another asteroid knocks an asteroid
+	// off course
+	AsteroidKnocked
+	// ShieldHit is when an asteroid crashes into the Earth's shield
+	ShieldHit
+	// EarthHit is when an asteroid damages the Earth
+	EarthHit
+	// PowerUpCollected is when the player picks up a power-up
+	PowerUpCollected
+	// EarthDestroyed is when the game is lost
+	EarthDestroyed
+	// NewHighScore is when a lost game made it into the high scores
+	NewHighScore
+	// AsteroidLaunched is when the attacker sends an asteroid, in versus
+	AsteroidLaunched
+	// WaveStarted is when a fresh wave of asteroids is made
+	WaveStarted
+	// Quit is when the player wants to stop playing
+	Quit
+)
+
+// An Entity represents anything that can update itself in the game world
+type Entity interface {
+	Update(*World)
+}
+
+// A Mover is anything that moves, it's told when each tick starts so that it
+// can be drawn moving smoothly between ticks
+type Mover interface {
+	Settle()
+}
+
+// A Collidable is anything the moons can run into
+type Collidable interface {
+	Collide(*World, *Moon)
+}
+
+// World represents the main game state
+type World struct {
+	State        State
+	StateSince   int   // tick the current State was entered
+	Resume       State // what to go back to when the game is unpaused
+	PauseMenu    *Menu
+	SettingsMenu *Menu
+	ShopMenu     *Menu
+	shopLevels   [Upgrades]int // the level each upgrade in the ShopMenu shows
+	Options      Options
+	Width        int
+	Height       int
+	Sizes        Sizes
+	Versus       bool       // whether the second player launches the asteroids
+	Seed         int64      // what each wave's Rand is seeded from
+	Rand         *rand.Rand // all randomness in the game comes from here
+	Rotation     float64
+	Count        int
+	Score        int
+	Credits      int // to spend on upgrades between waves
+	Wave         int
+	HowMany      int
+	Moon         *Moon // the first of the Moons
+	Moons        Moons
+	Earth        *Earth
+	Asteroids    Asteroids
+	Crosshair    *Crosshair // the first of the Crosshairs
+	Crosshairs   []*Crosshair
+	PowerUps     PowerUps
+	Effects      Effects // what power-ups are in effect and for how long
+	HighScores   HighScores
+	Waves        Waves            // the waves to play, or nil to make them up as we go
+	Entities     *entity.Registry // everything in the game, updated in the order it was added
+	Grid         *Grid            // for finding asteroids near things quickly
+	Pool         *Pool            // asteroids and power-ups to reuse
+	near         Asteroids        // what the Grid found last
+	along        byDistance       // what the laser went through last
+	fragments    Asteroids        // split off by the last shot
+	shotExploded func()           // emits ShotExplosion, made once so shooting doesn't allocate
+	Scheduler    *Scheduler
+	Spawner      *Spawner
+	Input        Input   // what the player is doing this tick
+	Events       []Event // what happened this tick
+}
+
+// NewWorld sets up a new game world with default states and game objects,
+// the same seed always generates the same waves of asteroids
+func NewWorld(width, height int, sizes Sizes, seed int64) *World {
+	w := &World{
+		Width:     width,
+		Height:    height,
+		Sizes:     sizes,
+		Seed:      seed,
+		Rand:      rand.New(rand.NewSource(seed)),
+		HowMany:   HowManyStart,
+		Scheduler: &Scheduler{},
+		Spawner:   &Spawner{},
+		Grid:      NewGrid(GridCell, width, height),
+		Pool:      &Pool{},
+		State:     Title,
+		PauseMenu: NewPauseMenu(),
+		ShopMenu:  NewShopMenu(),
+		Options:   Options{Music: true, Sound: true},
+	}
+	w.SettingsMenu = NewSettingsMenu(w.Options)
+	w.shotExploded = func() { w.Emit(ShotExplosion) }
+
+	w.Earth = &Earth{
+		Object:   NewObject(sizes.Earth),
+		Impacted: false,
+	}
+	w.Earth.Reset()
+	w.Earth.Center = Vec{float64(width) / 2, float64(height) / 2}
+	w.Earth.Settle()
+
+	w.Crosshair = NewCrosshair(sizes, 0)
+	w.Crosshairs = []*Crosshair{w.Crosshair}
+	if CoOp {
+		w.Crosshairs = append(w.Crosshairs, NewCrosshair(sizes, 1))
+	}
+
+	w.Moon = NewMoon(sizes, MoonOrbitDistance, 0)
+	w.Moon.jump(w)
+	w.Moons = Moons{w.Moon}
+	for _, c := range w.Crosshairs {
+		c.Firing = w.Moon
+	}
+
+	w.Entities = entity.New()
+	w.Entities.Add(&w.Asteroids)
+	w.Entities.Add(&w.PowerUps)
+	w.Entities.Add(&w.Moons)
+	w.Entities.Add(w.Earth)
+	for _, c := range w.Crosshairs {
+		w.Entities.Add(c)
+	}
+
+	return w
+}
+
+// NewAsteroids makes a fresh set of asteroids placed using rng, with a mix of
+// the types of asteroids that can turn up in the given wave
+func NewAsteroids(rng *rand.Rand, sizes Sizes, howMany int, wave int) Asteroids {
+	return appendAsteroids(make(Asteroids, 0, howMany), nil, rng, sizes, howMany, wave)
+}
+
+// appendAsteroids is NewAsteroids adding to as, with asteroids from p
+func appendAsteroids(as Asteroids, p *Pool, rng *rand.Rand, sizes Sizes, howMany int, wave int) Asteroids {
+	edgeOfScreenOffset := sizes.Earth * EdgeOfScreenOffset
+	for i := 0; i < howMany; i++ {
+		rng.Float64() // was how far out it started, kept so seeds give the same waves as before
+		angle := rng.Float64() * math.Pi * 2
+		kind := pickAsteroidType(rng, wave)
+		as = append(as, p.Asteroid(kind, sizes, angle, edgeOfScreenOffset))
+	}
+
+	return as
+}
+
+// Emit records that an Event happened during this tick
+func (w *World) Emit(e Event) {
+	w.Events = append(w.Events, e)
+}
+
+// Update advances the game by one tick using the given player input
+func (w *World) Update(in Input) {
+	w.Input = in
+	w.Events = w.Events[:0]
+	entity.Each(w.Entities, func(_ entity.ID, m Mover) {
+		m.Settle()
+	})
+
+	switch w.State {
+	case Loading:
+		return
+	case Title:
+		// Pressing Esc on the title screen quits
+		if in.Pause {
+			w.Emit(Quit)
+			return
+		}
+	case Paused, Settings:
+		w.updateMenus(in)
+		return
+	default:
+		if in.Pause {
+			w.Pause()
+			return
+		}
+	}
+
+	w.Scheduler.Update()
+
+	// Impact logic
+	if w.Asteroids.Alive() && w.Asteroids.Impacting() {
+		w.Earth.Impacted = true
+	}
+
+	// Game over
+	if w.Earth.Impacted && w.State != GameOver {
+		if w.Asteroids.Alive() {
+			for _, v := range w.Asteroids {
+				v.Explosion.Exploding = true
+			}
+		} else {
+			log.Println("game over")
+			w.Enter(GameOver)
+			w.Emit(EarthDestroyed)
+			var rank int
+			w.HighScores, rank = w.HighScores.Add(HighScore{Score: w.Score, Wave: w.Wave})
+			if rank >= 0 {
+				log.Printf("new high score: %d\n", w.Score)
+				w.Emit(NewHighScore)
+			}
+		}
+	}
+
+	// Next wave
+	if w.State == Playing && !w.Asteroids.Alive() {
+		w.Wave++
+		w.openShop()
+	} else if w.State == WaveIntermission {
+		w.updateShop(in)
+	}
+
+	// Release more asteroids, or let the attacker launch them, and run down
+	// power-ups
+	if w.State == Playing {
+		if w.Versus {
+			w.Spawner.launch(w)
+		} else {
+			w.Spawner.Update(w)
+		}
+		w.Effects.Update()
+	}
+
+	// Global rotation for orbiting bodies
+	w.Rotation = w.Rotation - RotationSpeed
+
+	// Update object positions, and clear away anything that's done
+	entity.Each(w.Entities, func(_ entity.ID, e Entity) {
+		e.Update(w)
+	})
+	w.Entities.Expire()
+	w.sweep()
+
+	// On the title screen, click to start the game
+	if w.State == Title && in.Clicked {
+		w.StartOver()
+	}
+
+	// Game restart, after a moment to take a breath
+	if w.State == GameOver && in.Clicked && w.InStateFor(time.Second) {
+		w.reset()
+		w.Restart()
+		w.Enter(Playing)
+	}
+}
+
+// Restart starts the current wave with fresh asteroids, Rand is reseeded for
+// each wave so it's the same however the ones before it were played
+func (w *World) Restart() {
+	w.Emit(WaveStarted)
+	w.Rand.Seed(w.waveSeed())
+	w.Pool.Free(w.Asteroids...)
+	clear(w.Asteroids)
+	spec, ok := w.Waves.Spec(w.Wave)
+	if ok {
+		w.Count = spec.Count
+		w.Asteroids = spec.appendAsteroids(w.Asteroids[:0], w.Pool, w.Rand, w.Sizes, w.Wave)
+	} else {
+		spec = WaveSpec{Spread: -1} // from anywhere at the usual pace
+		w.Count = w.HowMany
+		w.Asteroids = appendAsteroids(w.Asteroids[:0], w.Pool, w.Rand, w.Sizes, w.HowMany, w.Wave)
+	}
+	w.Spawner.Start(w.Asteroids, spec)
+	w.Grid.Stale = true
+	w.Earth.Impacted = false
+	w.unlockMoons()
+}
+
+// waveSeed mixes the Seed and the Wave into a seed for the wave, like
+// SplitMix64 does
+func (w *World) waveSeed() int64 {
+	z := uint64(w.Seed) + uint64(w.Wave)*0x9e3779b97f4a7c15
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	return int64(z ^ z>>31)
+}
+
+// StartOver starts a new game from the first wave
+func (w *World) StartOver() {
+	w.Wave = 1
+	w.HowMany = HowManyStart
+	w.reset()
+	w.Restart()
+	w.Enter(Playing)
+}
+
+// reset clears away everything left from the last game apart from which wave
+// it got to
+func (w *World) reset() {
+	w.Scheduler.Clear()
+	w.Score = 0
+	w.clearPowerUps()
+	w.Effects = Effects{}
+	w.Earth.Reset()
+	w.ResetUpgrades()
+	w.ResetMoons()
+	for _, c := range w.Crosshairs {
+		c.Reset(w)
+	}
+}
@@ -0,0 +1,291 @@
+package sim
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// newTestWorld makes a World which has been clicked into its first wave and
+// released its first asteroid
+func newTestWorld(t *testing.T) *World {
+	t.Helper()
+	w := NewWorld(1280, 960, DefaultSizes, 1)
+	w.Update(Input{Clicked: true})
+	if w.Wave != 1 {
+		t.Fatalf("clicking didn't start the game, wave %d", w.Wave)
+	}
+	w.Update(Input{})
+	if w.Asteroids[0].Waiting {
+		t.Fatalf("first asteroid wasn't released")
+	}
+	return w
+}
+
+// hasEvent reports whether e happened during the last tick
+func hasEvent(w *World, e Event) bool {
+	for _, v := range w.Events {
+		if v == e {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStart(t *testing.T) {
+	w := NewWorld(1280, 960, DefaultSizes, 1)
+	for i := 0; i < 10; i++ {
+		w.Update(Input{})
+	}
+	if w.Wave != 0 || len(w.Asteroids) != 0 {
+		t.Fatalf("game started without a click")
+	}
+
+	w.Update(Input{Clicked: true})
+	if w.Count != HowManyStart || len(w.Asteroids) != HowManyStart {
+		t.Errorf("first wave has %d asteroids, want %d", w.Count, HowManyStart)
+	}
+}
+
+func TestNewAsteroidsGolden(t *testing.T) {
+	want := []struct{ angle, distance float64 }{
+		{0.4146933517195851, 504},
+		{1.3120466068973313, 504},
+		{2.407674511881994, 504},
+	}
+	got := NewAsteroids(rand.New(rand.NewSource(42)), DefaultSizes, len(want), 1)
+	for i, v := range want {
+		if got[i].Angle != v.angle || got[i].Distance != v.distance {
+			t.Errorf("asteroid %d at (%v, %v), want (%v, %v)",
+				i, got[i].Angle, got[i].Distance, v.angle, v.distance)
+		}
+	}
+}
+
+func TestSameSeedSameWaves(t *testing.T) {
+	a := NewWorld(1280, 960, DefaultSizes, 7)
+	b := NewWorld(1280, 960, DefaultSizes, 7)
+	for wave := 0; wave < 3; wave++ {
+		a.Restart()
+		b.Restart()
+		for i := range a.Asteroids {
+			if a.Asteroids[i].Angle != b.Asteroids[i].Angle ||
+				a.Asteroids[i].Distance != b.Asteroids[i].Distance {
+				t.Fatalf("wave %d asteroid %d differs between worlds", wave, i)
+			}
+		}
+	}
+}
+
+func TestStartOverSameWave(t *testing.T) {
+	w := NewWorld(1280, 960, DefaultSizes, 1)
+	w.StartOver()
+	first := make([]float64, len(w.Asteroids))
+	for i, v := range w.Asteroids {
+		first[i] = v.Angle
+	}
+
+	w.StartOver()
+	for i, v := range w.Asteroids {
+		if v.Angle != first[i] {
+			t.Fatalf("asteroid %d at %v after starting over, was %v", i, v.Angle, first[i])
+		}
+	}
+}
+
+func TestWaveSameHoweverPlayed(t *testing.T) {
+	// Play the first wave for a while, shooting everything or not, then
+	// wait for the second one
+	play := func(ticks int, shoot bool) *World {
+		w := NewWorld(1280, 960, DefaultSizes, 7)
+		w.StartOver()
+		for i := 0; i < ticks; i++ {
+			w.Update(Input{})
+		}
+		for _, v := range w.Asteroids {
+			if shoot && v.Hittable() {
+				w.destroy(v)
+			}
+			v.Alive = false
+		}
+		for i := 0; i < TPS*10 && (w.Wave != 2 || w.State != Playing); i++ {
+			w.Update(Input{})
+		}
+		if w.Wave != 2 || w.State != Playing {
+			t.Fatalf("wave %d, %v after clearing the first wave", w.Wave, w.State)
+		}
+		return w
+	}
+
+	a, b := play(TPS, true), play(TPS*5, false)
+	if len(a.Asteroids) != len(b.Asteroids) {
+		t.Fatalf("second wave has %d asteroids one way and %d the other", len(a.Asteroids), len(b.Asteroids))
+	}
+	for i := range a.Asteroids {
+		if !a.Asteroids[i].Waiting || !b.Asteroids[i].Waiting {
+			continue // already moving
+		}
+		if a.Asteroids[i].Angle != b.Asteroids[i].Angle || a.Asteroids[i].Type != b.Asteroids[i].Type {
+			t.Errorf("second wave asteroid %d differs depending on how the first was played", i)
+		}
+	}
+}
+
+func TestLaserHit(t *testing.T) {
+	w := newTestWorld(t)
+	target := w.Asteroids[0]
+	w.Update(Input{})
+
+	count := w.Count
+	w.Update(Input{Cursor: target.Center.Point(), Clicked: true})
+	if !hasEvent(w, LaserFired) || !hasEvent(w, AsteroidShot) {
+		t.Errorf("events = %v, want laser and hit", w.Events)
+	}
+	if !target.Explosion.Exploding {
+		t.Errorf("asteroid under crosshair not exploding")
+	}
+	if w.Count != count-1 {
+		t.Errorf("count = %d, want %d", w.Count, count-1)
+	}
+	if w.Crosshair.CoolingDown {
+		t.Errorf("cooling down after a hit")
+	}
+	if w.Score != ShotPoints(w.Crosshair.Combo) || w.Crosshair.Combo < 1 {
+		t.Errorf("score = %d after hitting %d", w.Score, w.Crosshair.Combo)
+	}
+
+	for i := 0; i < Ticks(time.Millisecond*100) && !hasEvent(w, ShotExplosion); i++ {
+		w.Update(Input{})
+	}
+	if !hasEvent(w, ShotExplosion) {
+		t.Errorf("shot asteroid never exploded")
+	}
+}
+
+func TestLaserMiss(t *testing.T) {
+	w := newTestWorld(t)
+	count := w.Count
+	w.Update(Input{Cursor: image.Pt(-1000, -1000), Clicked: true})
+	if !w.Crosshair.CoolingDown {
+		t.Errorf("not cooling down after a miss")
+	}
+	if w.Score != 0 {
+		t.Errorf("score = %d after a miss, want 0", w.Score)
+	}
+	if w.Count != count {
+		t.Errorf("count = %d, want %d", w.Count, count)
+	}
+
+	w.Update(Input{Cursor: image.Pt(-1000, -1000), Clicked: true})
+	if hasEvent(w, LaserFired) {
+		t.Errorf("fired while cooling down")
+	}
+
+	for i := 1; i < Ticks(time.Second); i++ {
+		w.Update(Input{})
+	}
+	if w.Crosshair.CoolingDown {
+		t.Errorf("still cooling down after a second")
+	}
+}
+
+func TestMoonRam(t *testing.T) {
+	w := newTestWorld(t)
+	target := w.Asteroids[0]
+	w.Moon.Phase = RotationSpeed / MoonOrbitRatio                      // so the Moon is at angle zero after the tick
+	target.Place(0, w.Moon.Radius*MoonOrbitDistance+1, w.Earth.Radius) // one more tick to go
+
+	count := w.Count
+	w.Update(Input{})
+	if !hasEvent(w, AsteroidRammed) {
+		t.Errorf("moon didn't ram asteroid at %v, moon at %v", target.Center, w.Moon.Center)
+	}
+	if w.Score != PointsRammed {
+		t.Errorf("score = %d, want %d", w.Score, PointsRammed)
+	}
+	if w.Count != count-1 {
+		t.Errorf("count = %d, want %d", w.Count, count-1)
+	}
+}
+
+func TestImpact(t *testing.T) {
+	w := newTestWorld(t)
+	w.Earth.Health, w.Earth.Shield = 1, 0 // one hit left
+	w.Asteroids[0].Place(0, 0, w.Earth.Radius)
+	w.Score = 1234
+
+	for i := 0; i < ExplosionFrames+2 && w.State != GameOver; i++ {
+		w.Update(Input{})
+	}
+	if w.State != GameOver {
+		t.Fatalf("asteroid hit the Earth without game over")
+	}
+	if !hasEvent(w, EarthDestroyed) {
+		t.Errorf("events = %v, want Earth destroyed", w.Events)
+	}
+	if w.Asteroids.Alive() {
+		t.Errorf("asteroids still alive after game over")
+	}
+	if !hasEvent(w, NewHighScore) || w.HighScores[0].Score != 1234 {
+		t.Errorf("game over didn't make a high score: %v", w.HighScores)
+	}
+}
+
+func TestNextWave(t *testing.T) {
+	w := newTestWorld(t)
+	for _, v := range w.Asteroids {
+		v.Alive = false
+	}
+
+	w.Update(Input{})
+	if w.Wave != 2 || w.State != WaveIntermission {
+		t.Fatalf("wave %d, %v after clearing the first wave", w.Wave, w.State)
+	}
+
+	pause := Ticks(time.Second * time.Duration(TimeBetweenWaves))
+	for i := 0; i < pause; i++ {
+		w.Update(Input{})
+	}
+	if w.State != Playing {
+		t.Errorf("still %v after %d ticks", w.State, pause)
+	}
+	if want := HowManyStart * WaveMultiplier; len(w.Asteroids) != want {
+		t.Errorf("second wave has %d asteroids, want %d", len(w.Asteroids), want)
+	}
+}
+
+// ship is something new in the game, it counts how often it's updated and
+// run into, and leaves once it's been updated enough
+type ship struct {
+	updates, collisions, stay int
+}
+
+func (s *ship) Update(w *World)           { s.updates++ }
+func (s *ship) Collide(w *World, m *Moon) { s.collisions++ }
+func (s *ship) Done() bool                { return s.stay > 0 && s.updates >= s.stay }
+
+func TestEntities(t *testing.T) {
+	w := newTestWorld(t)
+	s := &ship{}
+	id := w.Entities.Add(s)
+	w.Update(Input{})
+	if s.updates != 1 || s.collisions != len(w.Moons) {
+		t.Fatalf("updated %d times and run into %d times in a tick", s.updates, s.collisions)
+	}
+
+	w.Entities.Remove(id)
+	w.Update(Input{})
+	if s.updates != 1 {
+		t.Errorf("still updated after it was removed")
+	}
+
+	s = &ship{stay: 2}
+	id = w.Entities.Add(s)
+	for i := 0; i < 3; i++ {
+		w.Update(Input{})
+	}
+	if _, ok := w.Entities.Get(id); ok || s.updates != 2 {
+		t.Errorf("updated %d times, want it gone after 2", s.updates)
+	}
+}
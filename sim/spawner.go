@@ -0,0 +1,96 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+package sim
+
+import (
+	"math"
+	"time"
+)
+
+var (
+	MaxOnScreen int     = 40   // most asteroids in play at once, the rest wait
+	BurstSize   int     = 6    // most asteroids released in one burst
+	BurstGap    float64 = 0.2  // seconds between asteroids in a burst
+	Lull        float64 = 1.5  // seconds between bursts
+	BurstSpread float64 = 0.35 // how far apart asteroids in a burst can be, in radians
+)
+
+// A Spawner releases a wave's asteroids a few at a time, in bursts from one
+// direction with lulls in between, instead of all at once
+type Spawner struct {
+	Next     int     // index of the next asteroid to release
+	Burst    int     // how many asteroids are left in this burst
+	Angle    float64 // where this burst is coming from
+	Cooldown int     // ticks until the next asteroid can be released
+	Lull     int     // ticks between bursts in this wave
+	Held     int     // ticks since the attacker last launched, in versus
+	Aimed    bool    // whether the wave says where its asteroids come from, so each keeps its own angle
+}
+
+// Start sets all the asteroids of a new wave waiting to be released, paced so
+// they are all released over the wave's Spread, or at the usual pace if it's
+// negative
+func (s *Spawner) Start(as Asteroids, spec WaveSpec) {
+	for _, v := range as {
+		v.Waiting = true
+	}
+	*s = Spawner{
+		Lull:  Ticks(time.Duration(Lull * float64(time.Second))),
+		Aimed: len(spec.Sectors) > 0,
+	}
+
+	if spread := spec.Spread; spread >= 0 && len(as) > 0 {
+		averageBurst := float64(BurstSize+1) / 2
+		bursts := math.Ceil(float64(len(as)) / averageBurst)
+		gaps := float64(len(as)) * BurstGap * TPS
+		s.Lull = int(math.Max(0, (spread*TPS-gaps)/bursts))
+	}
+}
+
+// Update releases the next asteroid if it's time
+func (s *Spawner) Update(w *World) {
+	if s.Cooldown > 0 {
+		s.Cooldown--
+		return
+	}
+
+	next := s.waiting(w)
+	if next == nil {
+		return
+	}
+	if s.Burst == 0 {
+		s.Burst = 1 + w.Rand.Intn(BurstSize)
+		s.Angle = next.Angle
+	}
+
+	next.Waiting = false
+	angle := next.Angle
+	if !s.Aimed {
+		angle = s.Angle + (w.Rand.Float64()-0.5)*BurstSpread
+	}
+	next.Place(angle, w.Earth.Radius*EdgeOfScreenOffset, w.Earth.Radius)
+	next.Launch(w.Rand, w.Earth.Radius)
+	next.jump(w)
+	s.Next++
+	s.Burst--
+
+	s.Cooldown = Ticks(time.Duration(BurstGap * float64(time.Second)))
+	if s.Burst == 0 {
+		s.Cooldown += s.Lull
+	}
+}
+
+// waiting is the next asteroid waiting to be released, or nil if there
+// isn't one or there are already too many in play
+func (s *Spawner) waiting(w *World) *Asteroid {
+	// Skip anything that isn't waiting, like fragments of split asteroids
+	for s.Next < len(w.Asteroids) && !w.Asteroids[s.Next].Waiting {
+		s.Next++
+	}
+	if s.Next >= len(w.Asteroids) || w.Asteroids.InPlay() >= MaxOnScreen {
+		return nil
+	}
+	return w.Asteroids[s.Next]
+}
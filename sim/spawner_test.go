@@ -0,0 +1,92 @@
+package sim
+
+import "testing"
+
+func TestSpawnerReleasesOverTime(t *testing.T) {
+	w := newTestWorld(t)
+	waiting := func() int {
+		n := 0
+		for _, v := range w.Asteroids {
+			if v.Waiting {
+				n++
+			}
+		}
+		return n
+	}
+	if waiting() != len(w.Asteroids)-1 {
+		t.Fatalf("%d asteroids waiting after the first tick, want all but one", waiting())
+	}
+
+	for i := 0; i < TPS*10 && waiting() > 0; i++ {
+		w.Update(Input{})
+	}
+	if waiting() > 0 {
+		t.Errorf("%d asteroids still waiting after 10 seconds", waiting())
+	}
+	for _, v := range w.Asteroids {
+		if v.Distance > w.Earth.Radius*EdgeOfScreenOffset {
+			t.Errorf("asteroid released at %v, further than the edge of the screen", v.Distance)
+		}
+	}
+}
+
+func TestSpawnerMaxOnScreen(t *testing.T) {
+	defer func(n int) { MaxOnScreen = n }(MaxOnScreen)
+	MaxOnScreen = 2
+
+	w := newTestWorld(t)
+	for i := 0; i < TPS*5; i++ {
+		w.Update(Input{})
+		if n := w.Asteroids.InPlay(); n > MaxOnScreen {
+			t.Fatalf("%d asteroids in play, want at most %d", n, MaxOnScreen)
+		}
+	}
+	if w.Asteroids.InPlay() != MaxOnScreen {
+		t.Errorf("%d asteroids in play, want %d", w.Asteroids.InPlay(), MaxOnScreen)
+	}
+}
+
+func TestSpawnerWaitingNotHittable(t *testing.T) {
+	w := newTestWorld(t)
+	last := w.Asteroids[len(w.Asteroids)-1]
+	if !last.Waiting || last.Hittable() {
+		t.Fatalf("last asteroid waiting %v, hittable %v", last.Waiting, last.Hittable())
+	}
+	if !w.Asteroids.Alive() {
+		t.Errorf("waiting asteroids don't keep the wave going")
+	}
+}
+
+func TestSpawnerPace(t *testing.T) {
+	as := make(Asteroids, 20)
+	for i := range as {
+		as[i] = NewAsteroid(AsteroidTypeByName("Normal"), DefaultSizes, 0, 0)
+	}
+	var s, usual Spawner
+	usual.Start(as, WaveSpec{Spread: -1})
+	s.Start(as, WaveSpec{Spread: 60})
+	if s.Lull <= usual.Lull {
+		t.Errorf("20 asteroids over 60 seconds lull %d ticks, want more than the usual %d", s.Lull, usual.Lull)
+	}
+	s.Start(as, WaveSpec{Spread: 0})
+	if s.Lull != 0 {
+		t.Errorf("20 asteroids over 0 seconds lull %d ticks, want 0", s.Lull)
+	}
+}
+
+func TestSpawnerKeepsSectors(t *testing.T) {
+	w := NewWorld(1280, 960, DefaultSizes, 1)
+	w.Waves = Waves{{Count: 20, Sectors: []Sector{{From: 1, To: 1.1}}, Spread: -1, Speed: 1}}
+	w.StartOver()
+	for i := 0; i < TPS*60; i++ {
+		w.Spawner.Update(w)
+	}
+	for i, v := range w.Asteroids {
+		if v.Waiting {
+			t.Fatalf("asteroid %d still waiting after a minute", i)
+		}
+		if v.Angle < 1 || v.Angle > 1.1 {
+			t.Errorf("asteroid %d released at %v, outside its sector", i, v.Angle)
+		}
+	}
+}
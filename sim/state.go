@@ -0,0 +1,203 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+package sim
+
+import "time"
+
+// State is which part of the game the World is in
+type State int
+
+const (
+	// Loading is for frontends that need to get ready before the game can
+	// start, the World never enters it by itself
+	Loading State = iota
+	// Title is the title screen shown before the first wave
+	Title
+	// Playing is when asteroids are coming and can be shot
+	Playing
+	// Paused is when the pause menu is open
+	Paused
+	// WaveIntermission is the break between waves
+	WaveIntermission
+	// GameOver is when the Earth has been destroyed
+	GameOver
+	// Settings is when the settings menu is open
+	Settings
+)
+
+var stateNames = []string{
+	"Loading",
+	"Title",
+	"Playing",
+	"Paused",
+	"WaveIntermission",
+	"GameOver",
+	"Settings",
+}
+
+func (s State) String() string {
+	if s < 0 || int(s) >= len(stateNames) {
+		return "State(?)"
+	}
+	return stateNames[s]
+}
+
+// Enter switches the World to another State
+func (w *World) Enter(s State) {
+	w.State = s
+	w.StateSince = w.Scheduler.Now
+}
+
+// InStateFor reports whether the World has been in its current State for at
+// least the given duration of play
+func (w *World) InStateFor(d time.Duration) bool {
+	return w.Scheduler.Now-w.StateSince >= Ticks(d)
+}
+
+// Behind is the State of the game behind any open menus
+func (w *World) Behind() State {
+	if w.State == Paused || w.State == Settings {
+		return w.Resume
+	}
+	return w.State
+}
+
+// Pause opens the pause menu, remembering what to go back to
+func (w *World) Pause() {
+	w.Resume = w.State
+	w.PauseMenu.Selected = 0
+	w.Enter(Paused)
+}
+
+// Menu is the menu that is currently open, if any
+func (w *World) Menu() *Menu {
+	switch w.State {
+	case Paused:
+		return w.PauseMenu
+	case Settings:
+		return w.SettingsMenu
+	case WaveIntermission:
+		return w.ShopMenu
+	}
+	return nil
+}
+
+// A Menu is a list of things to choose from, one of which is selected
+type Menu struct {
+	Items    []string
+	Selected int
+}
+
+// Update moves the selection according to the player's input and returns the
+// index of the item they chose, or -1 if they didn't choose anything yet
+func (m *Menu) Update(in Input) int {
+	if in.Choice > 0 && in.Choice <= len(m.Items) {
+		m.Selected = in.Choice - 1
+		return m.Selected
+	}
+	if in.Up {
+		m.Selected = (m.Selected + len(m.Items) - 1) % len(m.Items)
+	}
+	if in.Down {
+		m.Selected = (m.Selected + 1) % len(m.Items)
+	}
+	if in.Confirm {
+		return m.Selected
+	}
+	return -1
+}
+
+// Items in the pause menu
+const (
+	MenuResume = iota
+	MenuRestart
+	MenuSettings
+	MenuQuit
+)
+
+// NewPauseMenu makes the menu shown when the game is paused
+func NewPauseMenu() *Menu {
+	return &Menu{Items: []string{"RESUME", "RESTART", "SETTINGS", "QUIT"}}
+}
+
+// Options are the player's settings, which don't change how the game plays
+type Options struct {
+	Music      bool
+	Sound      bool
+	Fullscreen bool
+}
+
+// Items in the settings menu
+const (
+	SettingsMusic = iota
+	SettingsSound
+	SettingsFullscreen
+	SettingsBack
+)
+
+// NewSettingsMenu makes the menu for changing Options
+func NewSettingsMenu(o Options) *Menu {
+	m := &Menu{}
+	m.Label(o)
+	return m
+}
+
+// Label updates the settings menu's items to show the current Options
+func (m *Menu) Label(o Options) {
+	onOff := func(b bool) string {
+		if b {
+			return "ON"
+		}
+		return "OFF"
+	}
+	m.Items = []string{
+		"MUSIC: " + onOff(o.Music),
+		"SOUND: " + onOff(o.Sound),
+		"FULLSCREEN: " + onOff(o.Fullscreen),
+		"BACK",
+	}
+}
+
+// updateMenus handles the player's input while a menu is open
+func (w *World) updateMenus(in Input) {
+	switch w.State {
+	case Paused:
+		if in.Pause {
+			w.Enter(w.Resume)
+			return
+		}
+		switch w.PauseMenu.Update(in) {
+		case MenuResume:
+			w.Enter(w.Resume)
+		case MenuRestart:
+			w.StartOver()
+		case MenuSettings:
+			if w.Versus {
+				break // the menus are shared in versus but Options are each player's own
+			}
+			w.SettingsMenu.Selected = 0
+			w.Enter(Settings)
+		case MenuQuit:
+			w.Emit(Quit)
+		}
+
+	case Settings:
+		if in.Pause {
+			w.Enter(Paused)
+			return
+		}
+		switch w.SettingsMenu.Update(in) {
+		case SettingsMusic:
+			w.Options.Music = !w.Options.Music
+		case SettingsSound:
+			w.Options.Sound = !w.Options.Sound
+		case SettingsFullscreen:
+			w.Options.Fullscreen = !w.Options.Fullscreen
+		case SettingsBack:
+			w.Enter(Paused)
+		}
+		w.SettingsMenu.Label(w.Options)
+	}
+}
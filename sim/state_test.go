@@ -0,0 +1,153 @@
+package sim
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPauseFreezes(t *testing.T) {
+	w := newTestWorld(t)
+	w.Update(Input{Pause: true})
+	if w.State != Paused || w.Menu() != w.PauseMenu {
+		t.Fatalf("state %v after pausing", w.State)
+	}
+
+	rotation, now := w.Rotation, w.Scheduler.Now
+	for i := 0; i < 100; i++ {
+		w.Update(Input{Clicked: true})
+	}
+	if w.Rotation != rotation || w.Scheduler.Now != now {
+		t.Errorf("game kept going while paused")
+	}
+
+	w.Update(Input{Pause: true})
+	if w.State != Playing {
+		t.Errorf("state %v after unpausing", w.State)
+	}
+}
+
+func TestPauseMenu(t *testing.T) {
+	cases := []struct {
+		name  string
+		input []Input
+		want  State
+		event Event
+	}{
+		{"resume", []Input{{Confirm: true}}, Playing, -1},
+		{"click resume", []Input{{Choice: MenuResume + 1}}, Playing, -1},
+		{"settings", []Input{{Down: true}, {Down: true}, {Confirm: true}}, Settings, -1},
+		{"quit", []Input{{Up: true}, {Confirm: true}}, Paused, Quit},
+		{"restart", []Input{{Choice: MenuRestart + 1}}, Playing, -1},
+	}
+	for _, c := range cases {
+		w := newTestWorld(t)
+		w.Update(Input{Pause: true})
+		for _, in := range c.input {
+			w.Update(in)
+		}
+		if w.State != c.want {
+			t.Errorf("%s: state %v, want %v", c.name, w.State, c.want)
+		}
+		if c.event >= 0 && !hasEvent(w, c.event) {
+			t.Errorf("%s: events %v, want %v", c.name, w.Events, c.event)
+		}
+	}
+}
+
+func TestTurretKeysInMenus(t *testing.T) {
+	w := newTestWorld(t)
+	w.Update(Input{Pause: true})
+	w.Update(Input{Turret: MenuQuit + 1})
+	if w.State != Paused || hasEvent(w, Quit) {
+		t.Errorf("turret key quit from the pause menu")
+	}
+
+	w = newTestWorld(t)
+	w.Credits = UpgradeCosts[FasterCooldown]
+	clearWave(t, w)
+	w.Update(Input{Turret: int(FasterCooldown) + 1})
+	w.Update(Input{Turret: ShopNextWave + 1})
+	if w.State != WaveIntermission || w.Crosshair.CooldownLevel != 0 {
+		t.Errorf("turret keys used the shop, state %v", w.State)
+	}
+}
+
+func TestRestartFromPause(t *testing.T) {
+	w := newTestWorld(t)
+	for _, v := range w.Asteroids {
+		v.Alive = false
+	}
+	w.Update(Input{})
+	w.Score = 500
+	w.Update(Input{Pause: true})
+	w.Update(Input{Choice: MenuRestart + 1})
+
+	if w.Wave != 1 || w.Score != 0 || w.Count != HowManyStart {
+		t.Errorf("restart left wave %d, score %d, count %d", w.Wave, w.Score, w.Count)
+	}
+	if w.Scheduler.Pending() != 0 {
+		t.Errorf("next wave still scheduled after restarting")
+	}
+}
+
+func TestSettingsMenu(t *testing.T) {
+	w := newTestWorld(t)
+	w.Update(Input{Pause: true})
+	w.Update(Input{Choice: MenuSettings + 1})
+	w.Update(Input{Choice: SettingsMusic + 1})
+	if w.Options.Music {
+		t.Errorf("music still on")
+	}
+	if w.SettingsMenu.Items[SettingsMusic] != "MUSIC: OFF" {
+		t.Errorf("settings menu says %q", w.SettingsMenu.Items[SettingsMusic])
+	}
+	w.Update(Input{Pause: true})
+	if w.State != Paused {
+		t.Errorf("state %v after leaving settings, want paused", w.State)
+	}
+}
+
+func TestNoSettingsInVersus(t *testing.T) {
+	w := newTestWorld(t)
+	w.Versus = true
+	w.Update(Input{Pause: true})
+	w.Update(Input{Choice: MenuSettings + 1})
+	if w.State != Paused {
+		t.Errorf("state %v after choosing settings in versus, want paused", w.State)
+	}
+}
+
+func TestTitleQuit(t *testing.T) {
+	w := NewWorld(1280, 960, DefaultSizes, 1)
+	w.Update(Input{Pause: true})
+	if w.State != Title || !hasEvent(w, Quit) {
+		t.Errorf("Esc on the title screen didn't quit")
+	}
+}
+
+func TestGameOverBreath(t *testing.T) {
+	w := newTestWorld(t)
+	w.Earth.Health, w.Earth.Shield = 1, 0 // one hit left
+	w.Asteroids[0].Place(0, 0, w.Earth.Radius)
+	for i := 0; i < ExplosionFrames+2 && w.State != GameOver; i++ {
+		w.Update(Input{})
+	}
+	if w.State != GameOver {
+		t.Fatalf("state %v, want game over", w.State)
+	}
+
+	w.Update(Input{Clicked: true})
+	if w.State != GameOver {
+		t.Fatalf("restarted without taking a breath")
+	}
+	for i := 0; i < Ticks(time.Second); i++ {
+		w.Update(Input{})
+	}
+	w.Scheduler.After(2, func() { t.Errorf("timer from the last game went off") })
+	w.Update(Input{Clicked: true})
+	if w.State != Playing || w.Earth.Impacted {
+		t.Errorf("state %v after trying again", w.State)
+	}
+	w.Update(Input{})
+	w.Update(Input{})
+}
@@ -0,0 +1,67 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+package sim
+
+import (
+	"image"
+	"math"
+)
+
+// A Vec is a position, or how far something moves, in the game world, in
+// pixels but not rounded to them so that nothing jitters or loses track of
+// where it is between ticks
+type Vec struct {
+	X, Y float64
+}
+
+// FromPoint is the Vec at a whole pixel, like where the player is aiming
+func FromPoint(p image.Point) Vec {
+	return Vec{float64(p.X), float64(p.Y)}
+}
+
+// Polar is the Vec length away from the origin at the given angle
+func Polar(length, angle float64) Vec {
+	return Vec{length * math.Cos(angle), length * math.Sin(angle)}
+}
+
+// Add is v+u
+func (v Vec) Add(u Vec) Vec {
+	return Vec{v.X + u.X, v.Y + u.Y}
+}
+
+// Sub is v-u
+func (v Vec) Sub(u Vec) Vec {
+	return Vec{v.X - u.X, v.Y - u.Y}
+}
+
+// Mul is v scaled by k
+func (v Vec) Mul(k float64) Vec {
+	return Vec{v.X * k, v.Y * k}
+}
+
+// Dot is the dot product of v and u
+func (v Vec) Dot(u Vec) float64 {
+	return v.X*u.X + v.Y*u.Y
+}
+
+// Len is how long v is
+func (v Vec) Len() float64 {
+	return math.Hypot(v.X, v.Y)
+}
+
+// Angle is which way v points, like math.Atan2
+func (v Vec) Angle() float64 {
+	return math.Atan2(v.Y, v.X)
+}
+
+// Lerp is the Vec a fraction t of the way from v to u
+func (v Vec) Lerp(u Vec, t float64) Vec {
+	return Vec{v.X + (u.X-v.X)*t, v.Y + (u.Y-v.Y)*t}
+}
+
+// Point is the pixel v is in
+func (v Vec) Point() image.Point {
+	return image.Pt(int(math.Floor(v.X)), int(math.Floor(v.Y)))
+}
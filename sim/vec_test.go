@@ -0,0 +1,29 @@
+package sim
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestVec(t *testing.T) {
+	v, u := Vec{3, 4}, Vec{1, -2}
+	if got := v.Add(u).Sub(Vec{1, 1}); got != (Vec{3, 1}) {
+		t.Errorf("(3,4)+(1,-2)-(1,1) = %v", got)
+	}
+	if got := v.Len(); got != 5 {
+		t.Errorf("length of (3,4) = %v, want 5", got)
+	}
+	if got := v.Dot(u); got != -5 {
+		t.Errorf("(3,4).(1,-2) = %v, want -5", got)
+	}
+	if got := v.Lerp(u, 0.25); got != (Vec{2.5, 2.5}) {
+		t.Errorf("a quarter of the way from (3,4) to (1,-2) = %v", got)
+	}
+	if p := Polar(2, math.Pi/2); math.Abs(p.X) > 1e-9 || p.Y != 2 || math.Abs(p.Angle()-math.Pi/2) > 1e-9 {
+		t.Errorf("2 at a right angle = %v", p)
+	}
+	if got := (Vec{-0.5, 1.9}).Point(); got != image.Pt(-1, 1) {
+		t.Errorf("pixel of (-0.5,1.9) = %v, want (-1,1)", got)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+package sim
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"time"
+)
+
+var (
+	LaunchCooldown float64 = 0.4 // seconds the attacker waits between asteroids, in versus
+	HoldTime       float64 = 5   // seconds the attacker can hold back before the next asteroid launches anyway
+)
+
+// launch sends the next waiting asteroid at the Earth from wherever the
+// attacker is pointing when they click, in versus, instead of on a timer
+func (s *Spawner) launch(w *World) {
+	if s.Cooldown > 0 {
+		s.Cooldown--
+		return
+	}
+	next := s.waiting(w)
+	if next == nil {
+		return
+	}
+
+	aim := w.Input.Player2
+	s.Held++
+	if !aim.Clicked && s.Held < Ticks(time.Duration(HoldTime*float64(time.Second))) {
+		return
+	}
+
+	angle := FromPoint(aim.Cursor).Sub(w.Earth.Center).Angle()
+	next.Waiting = false
+	next.Place(angle, w.Earth.Radius*EdgeOfScreenOffset, w.Earth.Radius)
+	next.Launch(w.Rand, w.Earth.Radius)
+	next.jump(w)
+	s.Next++
+	s.Held = 0
+	s.Cooldown = Ticks(time.Duration(LaunchCooldown * float64(time.Second)))
+	w.Emit(AsteroidLaunched)
+}
+
+// Checksum sums up the state of the game, two worlds given the same seed and
+// input should always have the same Checksum, if they don't then they've
+// drifted apart
+func (w *World) Checksum() uint32 {
+	h := fnv.New32a()
+	var buf []byte
+	for _, v := range []int{int(w.State), w.Count, w.Wave, w.Score, w.Credits, len(w.Asteroids)} {
+		buf = binary.AppendVarint(buf, int64(v))
+	}
+	buf = binary.AppendUvarint(buf, math.Float64bits(w.Earth.Health))
+	buf = binary.AppendUvarint(buf, math.Float64bits(w.Moon.Phase))
+	if w.Earth.Impacted {
+		buf = append(buf, 1)
+	}
+	for _, v := range w.Asteroids {
+		buf = binary.AppendUvarint(buf, math.Float64bits(v.Position.X))
+		buf = binary.AppendUvarint(buf, math.Float64bits(v.Position.Y))
+		buf = binary.AppendVarint(buf, int64(v.HitPoints))
+	}
+	h.Write(buf)
+	return h.Sum32()
+}
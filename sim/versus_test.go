@@ -0,0 +1,39 @@
+package sim
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLaunch(t *testing.T) {
+	w := NewWorld(1280, 960, DefaultSizes, 1)
+	w.Versus = true
+	w.Update(Input{Clicked: true})
+	w.Update(Input{})
+	if w.Asteroids.InPlay() != 0 {
+		t.Fatalf("%d asteroids launched before the attacker did anything", w.Asteroids.InPlay())
+	}
+
+	// Launching from the right of the Earth
+	right := w.Earth.Center.Add(Vec{400, 0}).Point()
+	w.Update(Input{Player2: Aim{Cursor: right, Clicked: true}})
+	if !hasEvent(w, AsteroidLaunched) || w.Asteroids.InPlay() != 1 {
+		t.Fatalf("events = %v with %d in play, want one launched", w.Events, w.Asteroids.InPlay())
+	}
+	if a := w.Asteroids[0]; math.Abs(a.Angle) > 0.1 {
+		t.Errorf("asteroid launched at angle %v, want from the right", a.Angle)
+	}
+
+	w.Update(Input{Player2: Aim{Cursor: right, Clicked: true}})
+	if hasEvent(w, AsteroidLaunched) {
+		t.Errorf("launched again without waiting")
+	}
+
+	// Holding back too long launches anyway
+	for i := 0; i < TPS*int(HoldTime+LaunchCooldown+1) && !hasEvent(w, AsteroidLaunched); i++ {
+		w.Update(Input{Player2: Aim{Cursor: right}})
+	}
+	if w.Asteroids.InPlay() != 2 {
+		t.Errorf("%d asteroids in play after holding back, want 2", w.Asteroids.InPlay())
+	}
+}
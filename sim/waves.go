@@ -0,0 +1,246 @@
+// Copyright 2020 Siôn le Roux.  All rights reserved.
+// Use of this source code is subject to an MIT-style
+// licence which can be found in the LICENSE file.
+
+package sim
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Limits on waves, so a broken waves file or replay can't make us allocate
+// gigabytes or overflow
+const (
+	maxCount  = 100000 // most asteroids a wave can have
+	maxWeight = 1000   // most a type of asteroid can weigh in a wave's mix
+)
+
+// A WaveSpec describes a wave of asteroids
+type WaveSpec struct {
+	Count   int          // how many asteroids
+	Types   []TypeWeight // which types of asteroid, empty for the usual mix
+	Sectors []Sector     // where the asteroids come from, empty for anywhere
+	Spread  float64      // how many seconds apart the first and last asteroids are released, negative for the usual pace
+	Speed   float64      // how much faster than usual the asteroids move
+}
+
+// A TypeWeight is how common a type of asteroid is in a wave
+type TypeWeight struct {
+	Type   *AsteroidType
+	Weight int
+}
+
+// A Sector is a range of angles around the Earth, in radians
+type Sector struct {
+	From float64
+	To   float64
+}
+
+// Waves are the waves of a game in order, starting from the first
+type Waves []WaveSpec
+
+// Spec is the WaveSpec for the given wave, waves after the last one are like
+// it with WaveMultiplier times more asteroids each time, it reports false if
+// there are no waves at all
+func (ws Waves) Spec(wave int) (WaveSpec, bool) {
+	if len(ws) == 0 || wave < 1 {
+		return WaveSpec{}, false
+	}
+	if wave <= len(ws) {
+		return ws[wave-1], true
+	}
+	spec := ws[len(ws)-1]
+	for i := len(ws); i < wave; i++ {
+		spec.Count *= WaveMultiplier
+	}
+	return spec, true
+}
+
+// NewAsteroids makes a set of asteroids as described by the WaveSpec, placed
+// using rng
+func (s WaveSpec) NewAsteroids(rng *rand.Rand, sizes Sizes, wave int) Asteroids {
+	return s.appendAsteroids(make(Asteroids, 0, s.Count), nil, rng, sizes, wave)
+}
+
+// appendAsteroids is NewAsteroids adding to as, with asteroids from p
+func (s WaveSpec) appendAsteroids(as Asteroids, p *Pool, rng *rand.Rand, sizes Sizes, wave int) Asteroids {
+	edgeOfScreenOffset := sizes.Earth * EdgeOfScreenOffset
+	total := 0.0
+	for _, v := range s.Sectors {
+		total += v.To - v.From
+	}
+	weights := 0
+	for _, v := range s.Types {
+		weights += v.Weight
+	}
+
+	for i := 0; i < s.Count; i++ {
+		rng.Float64() // was how far out it started, kept so seeds give the same waves as before
+
+		angle := rng.Float64() * math.Pi * 2
+		if total > 0 {
+			// Pick a point along all the sectors laid end to end
+			angle = rng.Float64() * total
+			for _, v := range s.Sectors {
+				if angle <= v.To-v.From {
+					angle += v.From
+					break
+				}
+				angle -= v.To - v.From
+			}
+		}
+
+		kind := pickAsteroidType(rng, wave)
+		if weights > 0 {
+			n := rng.Intn(weights)
+			for _, v := range s.Types {
+				if n < v.Weight {
+					kind = v.Type
+					break
+				}
+				n -= v.Weight
+			}
+		}
+
+		asteroid := p.Asteroid(kind, sizes, angle, edgeOfScreenOffset)
+		asteroid.Speed *= s.Speed
+		as = append(as, asteroid)
+	}
+
+	return as
+}
+
+// LoadWaves reads waves from an ini file with a section for each wave named
+// [Wave 1], [Wave 2] and so on, e.g.
+//
+//	[Wave 1]
+//	Count  = 10             ; how many asteroids, the only thing needed
+//	Types  = Normal:3, Small ; which types of asteroid and how common each is
+//	Angles = 0-90, 180-270   ; where they come from in degrees, 0 is right
+//	Spread = 5               ; over how many seconds they arrive
+//	Speed  = 1.5             ; how much faster than usual they move
+func LoadWaves(r io.Reader) (Waves, error) {
+	cfg, err := ini.Load(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var waves Waves
+	for _, section := range cfg.Sections() {
+		name := section.Name()
+		if name == ini.DefaultSection {
+			if len(section.Keys()) > 0 {
+				return nil, fmt.Errorf("%s is not in a [Wave n] section", section.Keys()[0].Name())
+			}
+			continue
+		}
+
+		n, err := strconv.Atoi(strings.TrimPrefix(name, "Wave "))
+		if !strings.HasPrefix(name, "Wave ") || err != nil {
+			return nil, fmt.Errorf("[%s] should be called [Wave n] where n is the wave number", name)
+		}
+		if n != len(waves)+1 {
+			return nil, fmt.Errorf("[%s] should be [Wave %d], waves must be in order without gaps", name, len(waves)+1)
+		}
+
+		spec, err := parseWave(section)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] %w", name, err)
+		}
+		waves = append(waves, spec)
+	}
+
+	return waves, nil
+}
+
+// parseWave reads a single wave's section
+func parseWave(section *ini.Section) (WaveSpec, error) {
+	spec := WaveSpec{Spread: -1, Speed: 1}
+	for _, key := range section.Keys() {
+		var err error
+		value := key.String()
+		switch key.Name() {
+		case "Count":
+			spec.Count, err = strconv.Atoi(value)
+			if err == nil && spec.Count < 1 {
+				err = fmt.Errorf("must be at least 1")
+			} else if err == nil && spec.Count > maxCount {
+				err = fmt.Errorf("can't be more than %d", maxCount)
+			}
+		case "Types":
+			spec.Types, err = parseTypes(value)
+		case "Angles":
+			spec.Sectors, err = parseAngles(value)
+		case "Spread":
+			spec.Spread, err = strconv.ParseFloat(value, 64)
+			if err == nil && math.IsNaN(spec.Spread) {
+				err = fmt.Errorf("must be a number")
+			} else if err == nil && spec.Spread < 0 {
+				err = fmt.Errorf("can't be negative")
+			}
+		case "Speed":
+			spec.Speed, err = strconv.ParseFloat(value, 64)
+			if err == nil && !(spec.Speed > 0) {
+				err = fmt.Errorf("must be more than 0")
+			}
+		default:
+			return spec, fmt.Errorf("unknown setting %s", key.Name())
+		}
+		if err != nil {
+			return spec, fmt.Errorf("%s = %s: %w", key.Name(), value, err)
+		}
+	}
+
+	if spec.Count == 0 {
+		return spec, fmt.Errorf("Count is missing")
+	}
+	return spec, nil
+}
+
+// parseTypes reads a list like "Normal:3, Small" where the number is how
+// common that type is, one if it's left out
+func parseTypes(s string) ([]TypeWeight, error) {
+	var types []TypeWeight
+	for _, item := range strings.Split(s, ",") {
+		name, weight, found := strings.Cut(strings.TrimSpace(item), ":")
+		tw := TypeWeight{Type: AsteroidTypeByName(strings.TrimSpace(name)), Weight: 1}
+		if tw.Type == nil {
+			return nil, fmt.Errorf("unknown asteroid type %q", strings.TrimSpace(name))
+		}
+		if found {
+			var err error
+			tw.Weight, err = strconv.Atoi(strings.TrimSpace(weight))
+			if err != nil || tw.Weight < 1 || tw.Weight > maxWeight {
+				return nil, fmt.Errorf("%s should be a whole number from 1 to %d", item, maxWeight)
+			}
+		}
+		types = append(types, tw)
+	}
+	return types, nil
+}
+
+// parseAngles reads a list of ranges in degrees like "0-90, 180-270" and
+// turns them into Sectors
+func parseAngles(s string) ([]Sector, error) {
+	var sectors []Sector
+	for _, item := range strings.Split(s, ",") {
+		from, to, found := strings.Cut(strings.TrimSpace(item), "-")
+		if !found {
+			return nil, fmt.Errorf("%q should be a range like 0-90", strings.TrimSpace(item))
+		}
+		f, err1 := strconv.ParseFloat(strings.TrimSpace(from), 64)
+		t, err2 := strconv.ParseFloat(strings.TrimSpace(to), 64)
+		if err1 != nil || err2 != nil || f < 0 || t > 360 || f >= t {
+			return nil, fmt.Errorf("%q should be a range between 0 and 360 like 0-90", strings.TrimSpace(item))
+		}
+		sectors = append(sectors, Sector{From: f * math.Pi / 180, To: t * math.Pi / 180})
+	}
+	return sectors, nil
+}
@@ -0,0 +1,126 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+const testWaves = `
+; a short campaign
+[Wave 1]
+Count = 4
+
+[Wave 2]
+Count  = 6
+Types  = Small:2, Armoured
+Angles = 0-90, 180-270
+Spread = 3
+Speed  = 1.5
+`
+
+func TestLoadWaves(t *testing.T) {
+	waves, err := LoadWaves(strings.NewReader(testWaves))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(waves) != 2 {
+		t.Fatalf("loaded %d waves, want 2", len(waves))
+	}
+	if waves[0].Count != 4 || waves[0].Spread >= 0 || waves[0].Speed != 1 || waves[0].Types != nil {
+		t.Errorf("wave 1 = %+v, want defaults with 4 asteroids", waves[0])
+	}
+	second := waves[1]
+	if second.Count != 6 || second.Spread != 3 || second.Speed != 1.5 {
+		t.Errorf("wave 2 = %+v", second)
+	}
+	if len(second.Types) != 2 || second.Types[0].Type.Name != "Small" || second.Types[0].Weight != 2 || second.Types[1].Weight != 1 {
+		t.Errorf("wave 2 types = %+v", second.Types)
+	}
+	if len(second.Sectors) != 2 || second.Sectors[1].From != math.Pi || second.Sectors[1].To != math.Pi*3/2 {
+		t.Errorf("wave 2 sectors = %+v", second.Sectors)
+	}
+}
+
+func TestLoadWavesErrors(t *testing.T) {
+	cases := []struct {
+		input, err string
+	}{
+		{"Count = 3", "not in a [Wave n] section"},
+		{"[Level 1]\nCount = 3", "[Level 1] should be called [Wave n]"},
+		{"[Wave 2]\nCount = 3", "should be [Wave 1]"},
+		{"[Wave 1]\nSpeed = 2", "Count is missing"},
+		{"[Wave 1]\nCount = 0", "Count = 0: must be at least 1"},
+		{"[Wave 1]\nCount = 1000000", "Count = 1000000: can't be more than"},
+		{"[Wave 1]\nCount = lots", "Count = lots"},
+		{"[Wave 1]\nCount = 3\nTypes = Smal", `unknown asteroid type "Smal"`},
+		{"[Wave 1]\nCount = 3\nTypes = Small:0", "Small:0 should be a whole number"},
+		{"[Wave 1]\nCount = 3\nAngles = 90", `"90" should be a range`},
+		{"[Wave 1]\nCount = 3\nAngles = 90-400", `"90-400" should be a range between 0 and 360`},
+		{"[Wave 1]\nCount = 3\nSpeed = 0", "Speed = 0: must be more than 0"},
+		{"[Wave 1]\nCount = 3\nSpeed = NaN", "Speed = NaN: must be more than 0"},
+		{"[Wave 1]\nCount = 3\nSpread = NaN", "Spread = NaN: must be a number"},
+		{"[Wave 1]\nCount = 3\nSpread = -1", "Spread = -1: can't be negative"},
+		{"[Wave 1]\nCount = 3\nCuont = 4", "unknown setting Cuont"},
+	}
+	for _, c := range cases {
+		_, err := LoadWaves(strings.NewReader(c.input))
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("LoadWaves(%q) error %v, want %q", c.input, err, c.err)
+		}
+	}
+}
+
+func TestWavesSpec(t *testing.T) {
+	if _, ok := Waves(nil).Spec(1); ok {
+		t.Errorf("no waves gave a spec")
+	}
+	waves := Waves{{Count: 2}, {Count: 5}}
+	if spec, _ := waves.Spec(2); spec.Count != 5 {
+		t.Errorf("wave 2 has %d asteroids, want 5", spec.Count)
+	}
+	if spec, _ := waves.Spec(4); spec.Count != 5*WaveMultiplier*WaveMultiplier {
+		t.Errorf("wave 4 has %d asteroids, want %d", spec.Count, 5*WaveMultiplier*WaveMultiplier)
+	}
+}
+
+func TestWaveSpecAsteroids(t *testing.T) {
+	waves, err := LoadWaves(strings.NewReader(testWaves))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := waves[1]
+	asteroids := spec.NewAsteroids(rand.New(rand.NewSource(1)), DefaultSizes, 2)
+	if len(asteroids) != spec.Count {
+		t.Fatalf("made %d asteroids, want %d", len(asteroids), spec.Count)
+	}
+	for _, v := range asteroids {
+		inSector := false
+		for _, s := range spec.Sectors {
+			inSector = inSector || (v.Angle >= s.From && v.Angle <= s.To)
+		}
+		if !inSector {
+			t.Errorf("asteroid at angle %v is outside the wave's sectors", v.Angle)
+		}
+		if v.Type.Name != "Small" && v.Type.Name != "Armoured" {
+			t.Errorf("%s asteroid in the wave", v.Type.Name)
+		}
+		if v.Speed != v.Type.Speed*spec.Speed {
+			t.Errorf("asteroid speed %v, want %v", v.Speed, v.Type.Speed*spec.Speed)
+		}
+		furthest := DefaultSizes.Earth*EdgeOfScreenOffset + spec.Spread*TPS
+		if v.Distance > furthest {
+			t.Errorf("asteroid %v away, further than %v", v.Distance, furthest)
+		}
+	}
+}
+
+func TestWorldWaves(t *testing.T) {
+	w := NewWorld(1280, 960, DefaultSizes, 1)
+	w.Waves = Waves{{Count: 2, Spread: -1, Speed: 1}}
+	w.Update(Input{Clicked: true})
+	if w.Count != 2 || len(w.Asteroids) != 2 {
+		t.Errorf("first wave has %d asteroids, want 2", w.Count)
+	}
+}